@@ -15,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
 	"code.cloudfoundry.org/quarks-job/pkg/kube/operator"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	"code.cloudfoundry.org/quarks-job/version"
 	"code.cloudfoundry.org/quarks-utils/pkg/cmd"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
@@ -56,6 +57,48 @@ var rootCmd = &cobra.Command{
 
 		cfg.MaxQuarksJobWorkers = viper.GetInt("max-workers")
 
+		opConfig := operatorconfig.NewDefaultConfig()
+
+		err = opConfig.SetupServiceAccountToken(
+			viper.GetString("service-account-token-audience"),
+			viper.GetInt64("service-account-token-expiration"),
+		)
+		if err != nil {
+			return wrapError(err, "")
+		}
+
+		err = opConfig.SetupSidecar(
+			viper.GetString("sidecar-resources"),
+			viper.GetString("sidecar-security-context"),
+		)
+		if err != nil {
+			return wrapError(err, "")
+		}
+
+		err = opConfig.SetupOutputVolumeSizeLimit(viper.GetString("output-volume-size-limit"))
+		if err != nil {
+			return wrapError(err, "")
+		}
+
+		err = opConfig.SetupTriggerServer(
+			viper.GetString("trigger-server-address"),
+			viper.GetString("trigger-server-cert-file"),
+			viper.GetString("trigger-server-key-file"),
+			viper.GetString("trigger-server-audience"),
+			viper.GetBool("trigger-server-insecure"),
+		)
+		if err != nil {
+			return wrapError(err, "")
+		}
+
+		err = opConfig.SetupConcurrencyLimits(
+			viper.GetInt("max-running-jobs"),
+			viper.GetInt("max-running-jobs-per-namespace"),
+		)
+		if err != nil {
+			return wrapError(err, "")
+		}
+
 		cmd.CtxTimeOut(cfg)
 		cmd.Meltdown(cfg)
 
@@ -66,7 +109,7 @@ var rootCmd = &cobra.Command{
 			return wrapError(err, "Couldn't apply CRDs.")
 		}
 
-		mgr, err := operator.NewManager(ctx, cfg, restConfig, manager.Options{
+		mgr, err := operator.NewManager(ctx, cfg, opConfig, restConfig, manager.Options{
 			MetricsBindAddress: "0",
 			LeaderElection:     false,
 		})
@@ -115,6 +158,54 @@ func init() {
 	viper.BindPFlag("max-workers", pf.Lookup("max-workers"))
 	argToEnv["max-workers"] = "MAX_WORKERS"
 
+	pf.String("service-account-token-audience", "", "Audience of the projected service account token used by the persist-output container, defaults to the API server's audience")
+	viper.BindPFlag("service-account-token-audience", pf.Lookup("service-account-token-audience"))
+	argToEnv["service-account-token-audience"] = "SERVICE_ACCOUNT_TOKEN_AUDIENCE"
+
+	pf.Int64("service-account-token-expiration", operatorconfig.DefaultServiceAccountTokenExpiration, "Expiration (in seconds) of the projected service account token used by the persist-output container")
+	viper.BindPFlag("service-account-token-expiration", pf.Lookup("service-account-token-expiration"))
+	argToEnv["service-account-token-expiration"] = "SERVICE_ACCOUNT_TOKEN_EXPIRATION"
+
+	pf.String("sidecar-resources", "", "JSON encoded default resource requirements of the output-persist container")
+	viper.BindPFlag("sidecar-resources", pf.Lookup("sidecar-resources"))
+	argToEnv["sidecar-resources"] = "SIDECAR_RESOURCES"
+
+	pf.String("sidecar-security-context", "", "JSON encoded default security context of the output-persist container, defaults to the restricted Pod Security Standard")
+	viper.BindPFlag("sidecar-security-context", pf.Lookup("sidecar-security-context"))
+	argToEnv["sidecar-security-context"] = "SIDECAR_SECURITY_CONTEXT"
+
+	pf.String("output-volume-size-limit", operatorconfig.DefaultOutputVolumeSizeLimit, "Size limit of the emptyDir volumes for job output, empty for no limit")
+	viper.BindPFlag("output-volume-size-limit", pf.Lookup("output-volume-size-limit"))
+	argToEnv["output-volume-size-limit"] = "OUTPUT_VOLUME_SIZE_LIMIT"
+
+	pf.String("trigger-server-address", "", "Listen address of the HTTP endpoint to trigger quarks jobs, e.g. ':8443', empty to disable it")
+	viper.BindPFlag("trigger-server-address", pf.Lookup("trigger-server-address"))
+	argToEnv["trigger-server-address"] = "TRIGGER_SERVER_ADDRESS"
+
+	pf.String("trigger-server-cert-file", "", "PEM encoded TLS certificate of the HTTP trigger endpoint")
+	viper.BindPFlag("trigger-server-cert-file", pf.Lookup("trigger-server-cert-file"))
+	argToEnv["trigger-server-cert-file"] = "TRIGGER_SERVER_CERT_FILE"
+
+	pf.String("trigger-server-key-file", "", "PEM encoded TLS key of the HTTP trigger endpoint")
+	viper.BindPFlag("trigger-server-key-file", pf.Lookup("trigger-server-key-file"))
+	argToEnv["trigger-server-key-file"] = "TRIGGER_SERVER_KEY_FILE"
+
+	pf.String("trigger-server-audience", operatorconfig.DefaultTriggerServerAudience, "Audience, which the tokens of requests to the HTTP trigger endpoint need")
+	viper.BindPFlag("trigger-server-audience", pf.Lookup("trigger-server-audience"))
+	argToEnv["trigger-server-audience"] = "TRIGGER_SERVER_AUDIENCE"
+
+	pf.Bool("trigger-server-insecure", false, "Serve the HTTP trigger endpoint without TLS, if no certificate is set")
+	viper.BindPFlag("trigger-server-insecure", pf.Lookup("trigger-server-insecure"))
+	argToEnv["trigger-server-insecure"] = "TRIGGER_SERVER_INSECURE"
+
+	pf.Int("max-running-jobs", 0, "Maximum number of jobs created by quarks jobs, which run at the same time in the cluster, 0 for no limit")
+	viper.BindPFlag("max-running-jobs", pf.Lookup("max-running-jobs"))
+	argToEnv["max-running-jobs"] = "MAX_RUNNING_JOBS"
+
+	pf.Int("max-running-jobs-per-namespace", 0, "Maximum number of jobs created by quarks jobs, which run at the same time in a namespace, 0 for no limit. Namespaces can override it with the 'quarks.cloudfoundry.org/max-running-jobs' annotation")
+	viper.BindPFlag("max-running-jobs-per-namespace", pf.Lookup("max-running-jobs-per-namespace"))
+	argToEnv["max-running-jobs-per-namespace"] = "MAX_RUNNING_JOBS_PER_NAMESPACE"
+
 	// Add env variables to help
 	cmd.AddEnvToUsage(rootCmd, argToEnv)
 
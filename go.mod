@@ -10,6 +10,7 @@ require (
 	github.com/spf13/afero v1.4.1
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/viper v1.7.0
+	go.mozilla.org/pkcs7 v0.9.0
 	go.uber.org/zap v1.16.0
 	golang.org/x/text v0.3.5 // indirect
 	golang.org/x/tools v0.0.0-20200708183856-df98bc6d456c // indirect
@@ -57,7 +57,7 @@ var _ = Describe("AutoErrandJob", func() {
 				err = env.WaitForJobDeletion(env.Namespace, jobs[0].Name)
 				Expect(err).ToNot(HaveOccurred())
 
-				By("Checking pod is still there, because delete label is missing")
+				By("Checking pod is still there, because the default cleanup policy keeps it")
 				Expect(env.PodsDeleted(env.Namespace)).To(BeFalse())
 
 				By("Checking qJob status")
@@ -66,6 +66,29 @@ var _ = Describe("AutoErrandJob", func() {
 				Expect(qj.Status.Completed).To(BeTrue())
 			})
 
+			Context("when the cleanup policy deletes pods", func() {
+				BeforeEach(func() {
+					qj.Spec.Cleanup = &qjv1a1.Cleanup{
+						OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
+					}
+				})
+
+				It("removes job's pod", func() {
+					_, tearDown, err := env.CreateQuarksJob(env.Namespace, qj)
+					Expect(err).NotTo(HaveOccurred())
+					defer func(tdf machine.TearDownFunc) { Expect(tdf()).To(Succeed()) }(tearDown)
+
+					jobs, err := env.CollectJobs(env.Namespace, quarksJobLabel, 1)
+					Expect(err).NotTo(HaveOccurred(), "error waiting for jobs from quarksJob")
+					Expect(jobs).To(HaveLen(1))
+
+					err = env.WaitForJobDeletion(env.Namespace, jobs[0].Name)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(env.WaitForPodsDelete(env.Namespace)).To(Succeed())
+				})
+			})
+
 			Context("when pod template has delete label", func() {
 				Context("when delete is set to pod", func() {
 					BeforeEach(func() {
@@ -8,6 +8,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"code.cloudfoundry.org/quarks-job/pkg/kube/operator"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	sharedcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
 )
 
@@ -45,7 +46,7 @@ func (e *Environment) setupOperator() (manager.Manager, error) {
 		return nil, err
 	}
 
-	mgr, err := operator.NewManager(ctx, e.Config, e.KubeConfig, manager.Options{
+	mgr, err := operator.NewManager(ctx, e.Config, operatorconfig.NewDefaultConfig(), e.KubeConfig, manager.Options{
 		MetricsBindAddress: "0",
 		LeaderElection:     false,
 		Host:               "0.0.0.0",
@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// This file is safe to edit
+// It's used as input for the Kube code generator
+// Run "make generate" after modifying this file
+
+// QuarksJobDefaultsSpec contains pod template fragments, which are merged
+// into the template of every QuarksJob. Values from the QuarksJob's
+// template take precedence.
+type QuarksJobDefaultsSpec struct {
+	// Labels are added to the job's pod
+	Labels map[string]string `json:"labels,omitempty"`
+	// NodeSelector entries are added to the job's pod
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations are added to the job's pod
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// ImagePullSecrets are added to the job's pod
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Resources are used for the template's containers, which don't
+	// specify any resources
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// SecretLabels are added to the output secrets
+	SecretLabels map[string]string `json:"secretLabels,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// QuarksJobDefaults is the schema for defaults, which apply to all
+// QuarksJobs in its namespace
+// +k8s:openapi-gen=true
+type QuarksJobDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec QuarksJobDefaultsSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// QuarksJobDefaultsList contains a list of QuarksJobDefaults
+type QuarksJobDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []QuarksJobDefaults `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterQuarksJobDefaults is the schema for defaults, which apply to all
+// QuarksJobs in the cluster. QuarksJobDefaults take precedence.
+// +k8s:openapi-gen=true
+type ClusterQuarksJobDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec QuarksJobDefaultsSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterQuarksJobDefaultsList contains a list of ClusterQuarksJobDefaults
+type ClusterQuarksJobDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterQuarksJobDefaults `json:"items"`
+}
@@ -20,8 +20,26 @@ const (
 	QuarksJobResourceKind = "QuarksJob"
 	// QuarksJobResourcePlural is the plural name of QuarksJob
 	QuarksJobResourcePlural = "quarksjobs"
+
+	// QuarksJobDefaultsResourceKind is the kind name of QuarksJobDefaults
+	QuarksJobDefaultsResourceKind = "QuarksJobDefaults"
+	// QuarksJobDefaultsResourcePlural is the plural name of QuarksJobDefaults
+	QuarksJobDefaultsResourcePlural = "quarksjobdefaults"
+
+	// ClusterQuarksJobDefaultsResourceKind is the kind name of ClusterQuarksJobDefaults
+	ClusterQuarksJobDefaultsResourceKind = "ClusterQuarksJobDefaults"
+	// ClusterQuarksJobDefaultsResourcePlural is the plural name of ClusterQuarksJobDefaults
+	ClusterQuarksJobDefaultsResourcePlural = "clusterquarksjobdefaults"
+
+	// QuarksWorkflowResourceKind is the kind name of QuarksWorkflow
+	QuarksWorkflowResourceKind = "QuarksWorkflow"
+	// QuarksWorkflowResourcePlural is the plural name of QuarksWorkflow
+	QuarksWorkflowResourcePlural = "quarksworkflows"
 )
 
+// minRetryMaxAttempts counts the first run
+var minRetryMaxAttempts float64 = 1
+
 var (
 	schemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
 
@@ -46,6 +64,44 @@ var (
 									Type:                   "object",
 									XPreserveUnknownFields: pointers.Bool(true),
 								},
+								"encryption": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"certificateRef": {
+											Type: "object",
+											Properties: map[string]extv1.JSONSchemaProps{
+												"kind": {
+													Type: "string",
+													Enum: []extv1.JSON{
+														{
+															Raw: []byte(`"ConfigMap"`),
+														},
+														{
+															Raw: []byte(`"Secret"`),
+														},
+													},
+												},
+												"name": {
+													Type: "string",
+												},
+												"key": {
+													Type: "string",
+												},
+											},
+											Required: []string{
+												"name",
+												"key",
+											},
+										},
+									},
+									Required: []string{
+										"certificateRef",
+									},
+								},
+								"logMap": {
+									Type:                   "object",
+									XPreserveUnknownFields: pointers.Bool(true),
+								},
 								"outputType": {
 									Type: "string",
 								},
@@ -53,13 +109,49 @@ var (
 									Type:                   "object",
 									XPreserveUnknownFields: pointers.Bool(true),
 								},
+								"sidecar": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"image": {
+											Type: "string",
+										},
+										"imagePullPolicy": {
+											Type: "string",
+											Enum: []extv1.JSON{
+												{
+													Raw: []byte(`"Always"`),
+												},
+												{
+													Raw: []byte(`"IfNotPresent"`),
+												},
+												{
+													Raw: []byte(`"Never"`),
+												},
+											},
+										},
+										"resources": {
+											Type:                   "object",
+											XPreserveUnknownFields: pointers.Bool(true),
+										},
+										"securityContext": {
+											Type:                   "object",
+											XPreserveUnknownFields: pointers.Bool(true),
+										},
+										"env": {
+											Type: "array",
+											Items: &extv1.JSONSchemaPropsOrArray{
+												Schema: &extv1.JSONSchemaProps{
+													Type:                   "object",
+													XPreserveUnknownFields: pointers.Bool(true),
+												},
+											},
+										},
+									},
+								},
 								"writeOnFailure": {
 									Type: "boolean",
 								},
 							},
-							Required: []string{
-								"outputMap",
-							},
 						},
 						"trigger": {
 							Type: "object",
@@ -81,6 +173,36 @@ var (
 										},
 									},
 								},
+								"debounce": {
+									Type: "string",
+								},
+								"watch": {
+									Type: "array",
+									Items: &extv1.JSONSchemaPropsOrArray{
+										Schema: &extv1.JSONSchemaProps{
+											Type:     "object",
+											Required: []string{"apiVersion", "kind"},
+											Properties: map[string]extv1.JSONSchemaProps{
+												"apiVersion": {
+													Type: "string",
+												},
+												"kind": {
+													Type: "string",
+												},
+												"name": {
+													Type: "string",
+												},
+												"selector": {
+													Type:                   "object",
+													XPreserveUnknownFields: pointers.Bool(true),
+												},
+												"jsonPath": {
+													Type: "string",
+												},
+											},
+										},
+									},
+								},
 							},
 							Required: []string{
 								"strategy",
@@ -93,6 +215,125 @@ var (
 						"updateOnConfigChange": {
 							Type: "boolean",
 						},
+						"watchReferences": {
+							Type: "object",
+							Properties: map[string]extv1.JSONSchemaProps{
+								"include": {
+									Type: "array",
+									Items: &extv1.JSONSchemaPropsOrArray{
+										Schema: &extv1.JSONSchemaProps{
+											Type: "string",
+										},
+									},
+								},
+								"exclude": {
+									Type: "array",
+									Items: &extv1.JSONSchemaPropsOrArray{
+										Schema: &extv1.JSONSchemaProps{
+											Type: "string",
+										},
+									},
+								},
+							},
+						},
+						"cleanup": {
+							Type: "object",
+							Properties: map[string]extv1.JSONSchemaProps{
+								"onSuccess": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"deleteJob": {
+											Type: "boolean",
+										},
+										"deletePods": {
+											Type: "boolean",
+										},
+									},
+								},
+								"onFailure": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"deleteJob": {
+											Type: "boolean",
+										},
+										"deletePods": {
+											Type: "boolean",
+										},
+									},
+								},
+								"ttlSecondsAfterFinished": {
+									Type: "integer",
+								},
+							},
+						},
+						"successfulJobsHistoryLimit": {
+							Type: "integer",
+						},
+						"failedJobsHistoryLimit": {
+							Type: "integer",
+						},
+						"retry": {
+							Type: "object",
+							Properties: map[string]extv1.JSONSchemaProps{
+								"maxAttempts": {
+									Type:    "integer",
+									Minimum: &minRetryMaxAttempts,
+								},
+								"backoff": {
+									Type: "string",
+								},
+								"onExitCodes": {
+									Type: "array",
+									Items: &extv1.JSONSchemaPropsOrArray{
+										Schema: &extv1.JSONSchemaProps{
+											Type: "integer",
+										},
+									},
+								},
+								"onOutputPersistenceFailure": {
+									Type: "boolean",
+								},
+							},
+							Required: []string{
+								"maxAttempts",
+							},
+						},
+						"runTimeout": {
+							Type: "string",
+						},
+						"suspend": {
+							Type: "boolean",
+						},
+						"priority": {
+							Type: "integer",
+						},
+						"dependsOn": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"name": {
+											Type: "string",
+										},
+										"outputs": {
+											Type: "array",
+											Items: &extv1.JSONSchemaPropsOrArray{
+												Schema: &extv1.JSONSchemaProps{
+													Type: "string",
+												},
+											},
+										},
+										"chain": {
+											Type: "boolean",
+										},
+									},
+									Required: []string{
+										"name",
+									},
+								},
+							},
+						},
 					},
 				},
 				"status": {
@@ -105,6 +346,63 @@ var (
 						"completed": {
 							Type: "boolean",
 						},
+						"outputs": {
+							Type:                   "object",
+							XPreserveUnknownFields: pointers.Bool(true),
+						},
+						"missingReferences": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type: "string",
+								},
+							},
+						},
+						"missingReferencesSince": {
+							Type:     "string",
+							Nullable: true,
+						},
+						"pendingRun": {
+							Type: "string",
+						},
+						"pendingAttempt": {
+							Type: "integer",
+						},
+						"waitingFor": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type: "string",
+								},
+							},
+						},
+						"parameters": {
+							Type: "object",
+							AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
+								Schema: &extv1.JSONSchemaProps{
+									Type: "string",
+								},
+							},
+						},
+						"configHash": {
+							Type: "string",
+						},
+						"succeededConfigHash": {
+							Type: "string",
+						},
+						"queuedSince": {
+							Type:     "string",
+							Nullable: true,
+						},
+						"conditions": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type:                   "object",
+									XPreserveUnknownFields: pointers.Bool(true),
+								},
+							},
+						},
 					},
 				},
 			},
@@ -134,11 +432,149 @@ var (
 			Priority:    20,
 			JSONPath:    ".spec.updateOnConfigChange",
 		},
+		{
+			Name:        "suspended",
+			Type:        "boolean",
+			Description: "",
+			Priority:    20,
+			JSONPath:    ".spec.suspend",
+		},
 	}
 
 	// QuarksJobResourceName is the resource name of QuarksJob
 	QuarksJobResourceName = fmt.Sprintf("%s.%s", QuarksJobResourcePlural, apis.GroupName)
 
+	// QuarksJobDefaultsResourceShortNames is the short names of QuarksJobDefaults
+	QuarksJobDefaultsResourceShortNames = []string{"qjobdefaults"}
+	// QuarksJobDefaultsResourceName is the resource name of QuarksJobDefaults
+	QuarksJobDefaultsResourceName = fmt.Sprintf("%s.%s", QuarksJobDefaultsResourcePlural, apis.GroupName)
+
+	// ClusterQuarksJobDefaultsResourceShortNames is the short names of ClusterQuarksJobDefaults
+	ClusterQuarksJobDefaultsResourceShortNames = []string{"cqjobdefaults"}
+	// ClusterQuarksJobDefaultsResourceName is the resource name of ClusterQuarksJobDefaults
+	ClusterQuarksJobDefaultsResourceName = fmt.Sprintf("%s.%s", ClusterQuarksJobDefaultsResourcePlural, apis.GroupName)
+
+	// QuarksJobDefaultsValidation is the validation method for
+	// QuarksJobDefaults and ClusterQuarksJobDefaults
+	QuarksJobDefaultsValidation = extv1.CustomResourceValidation{
+		OpenAPIV3Schema: &extv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]extv1.JSONSchemaProps{
+				"spec": {
+					Type: "object",
+					Properties: map[string]extv1.JSONSchemaProps{
+						"labels": {
+							Type:                   "object",
+							XPreserveUnknownFields: pointers.Bool(true),
+						},
+						"nodeSelector": {
+							Type:                   "object",
+							XPreserveUnknownFields: pointers.Bool(true),
+						},
+						"tolerations": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type:                   "object",
+									XPreserveUnknownFields: pointers.Bool(true),
+								},
+							},
+						},
+						"imagePullSecrets": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"name": {
+											Type: "string",
+										},
+									},
+								},
+							},
+						},
+						"resources": {
+							Type:                   "object",
+							XPreserveUnknownFields: pointers.Bool(true),
+						},
+						"secretLabels": {
+							Type:                   "object",
+							XPreserveUnknownFields: pointers.Bool(true),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// QuarksWorkflowResourceShortNames is the short names of QuarksWorkflow
+	QuarksWorkflowResourceShortNames = []string{"qwf", "qwfs"}
+	// QuarksWorkflowResourceName is the resource name of QuarksWorkflow
+	QuarksWorkflowResourceName = fmt.Sprintf("%s.%s", QuarksWorkflowResourcePlural, apis.GroupName)
+
+	// QuarksWorkflowValidation is the validation method for QuarksWorkflow
+	QuarksWorkflowValidation = extv1.CustomResourceValidation{
+		OpenAPIV3Schema: &extv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]extv1.JSONSchemaProps{
+				"spec": {
+					Type:     "object",
+					Required: []string{"steps"},
+					Properties: map[string]extv1.JSONSchemaProps{
+						"steps": {
+							Type: "array",
+							Items: &extv1.JSONSchemaPropsOrArray{
+								Schema: &extv1.JSONSchemaProps{
+									Type:     "object",
+									Required: []string{"name", "template"},
+									Properties: map[string]extv1.JSONSchemaProps{
+										"name": {
+											Type: "string",
+										},
+										"dependsOn": {
+											Type: "array",
+											Items: &extv1.JSONSchemaPropsOrArray{
+												Schema: &extv1.JSONSchemaProps{
+													Type: "string",
+												},
+											},
+										},
+										"template": QuarksJobValidation.OpenAPIV3Schema.Properties["spec"],
+									},
+								},
+							},
+						},
+						"failFast": {
+							Type: "boolean",
+						},
+					},
+				},
+				"status": {
+					Type:                   "object",
+					XPreserveUnknownFields: pointers.Bool(true),
+				},
+			},
+		},
+	}
+
+	// QuarksWorkflowAdditionalPrinterColumns are used by `kubectl get`
+	QuarksWorkflowAdditionalPrinterColumns = []extv1.CustomResourceColumnDefinition{
+		{
+			Name:        "phase",
+			Type:        "string",
+			Description: "",
+			Priority:    0,
+			JSONPath:    ".status.phase",
+		},
+		{
+			Name:        "failFast",
+			Type:        "boolean",
+			Description: "",
+			Priority:    10,
+			JSONPath:    ".spec.failFast",
+		},
+	}
+
 	// SchemeGroupVersion is group version used to register these objects
 	SchemeGroupVersion = schema.GroupVersion{Group: apis.GroupName, Version: "v1alpha1"}
 )
@@ -158,6 +594,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&QuarksJob{},
 		&QuarksJobList{},
+		&QuarksJobDefaults{},
+		&QuarksJobDefaultsList{},
+		&ClusterQuarksJobDefaults{},
+		&ClusterQuarksJobDefaultsList{},
+		&QuarksWorkflow{},
+		&QuarksWorkflowList{},
 	)
 
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
@@ -3,12 +3,17 @@ package v1alpha1
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	batchv1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"code.cloudfoundry.org/quarks-job/pkg/kube/apis"
+	"code.cloudfoundry.org/quarks-utils/pkg/names"
 )
 
 // This file is safe to edit
@@ -33,6 +38,48 @@ var (
 	// the remote resource they belong to
 	LabelRemoteID = fmt.Sprintf("%s/remote-id", apis.GroupName)
 
+	// AnnotationQJobName is set on persisted output to the name of the QuarksJob
+	AnnotationQJobName = fmt.Sprintf("%s/qjob-name", apis.GroupName)
+	// AnnotationQJobUID is set on persisted output to the UID of the QuarksJob
+	AnnotationQJobUID = fmt.Sprintf("%s/qjob-uid", apis.GroupName)
+	// AnnotationJobName is set on persisted output to the name of the batchv1.Job
+	AnnotationJobName = fmt.Sprintf("%s/job-name", apis.GroupName)
+	// AnnotationPodName is set on persisted output to the name of the job's pod
+	AnnotationPodName = fmt.Sprintf("%s/pod-name", apis.GroupName)
+	// AnnotationStartedAt is set on persisted output to the start time of the container
+	AnnotationStartedAt = fmt.Sprintf("%s/started-at", apis.GroupName)
+	// AnnotationFinishedAt is set on persisted output to the finish time of the container
+	AnnotationFinishedAt = fmt.Sprintf("%s/finished-at", apis.GroupName)
+	// AnnotationImageDigest is set on persisted output to the image ID of the container
+	AnnotationImageDigest = fmt.Sprintf("%s/image-digest", apis.GroupName)
+	// AnnotationChecksum is set on persisted output to the sha256 of its
+	// content before encryption
+	AnnotationChecksum = fmt.Sprintf("%s/checksum", apis.GroupName)
+	// AnnotationEncryption is set on persisted output, which was encrypted
+	// by the persist-output container
+	AnnotationEncryption = fmt.Sprintf("%s/encryption", apis.GroupName)
+
+	// AnnotationRunID is set on a batchv1.Job and its pod to the unique ID of the run
+	AnnotationRunID = fmt.Sprintf("%s/run-id", apis.GroupName)
+	// AnnotationTriggerReason is set on a batchv1.Job and its pod to the reason the run was started
+	AnnotationTriggerReason = fmt.Sprintf("%s/trigger-reason", apis.GroupName)
+	// AnnotationAttempt is set on a batchv1.Job and its pod to the number of the attempt, starting at 1
+	AnnotationAttempt = fmt.Sprintf("%s/attempt", apis.GroupName)
+	// AnnotationRetried is set on a failed batchv1.Job, once the next attempt was created
+	AnnotationRetried = fmt.Sprintf("%s/retried", apis.GroupName)
+	// AnnotationConfigHash is set on a batchv1.Job and its pod to the hash of the referenced configuration
+	AnnotationConfigHash = fmt.Sprintf("%s/config-hash", apis.GroupName)
+	// AnnotationCancel is set to "true" on a QuarksJob to terminate its running jobs
+	AnnotationCancel = fmt.Sprintf("%s/cancel", apis.GroupName)
+
+	// AnnotationMaxRunningJobs is set on a namespace to override the
+	// operator's limit of concurrently running jobs in the namespace
+	AnnotationMaxRunningJobs = fmt.Sprintf("%s/max-running-jobs", apis.GroupName)
+
+	// AnnotationDefaultSecretLabels is set on a batchv1.Job's pod to the
+	// JSON encoded secret labels from QuarksJobDefaults
+	AnnotationDefaultSecretLabels = fmt.Sprintf("%s/default-secret-labels", apis.GroupName)
+
 	// LabelQJobName key for label on a batchv1.Job's pod, which is set to the QuarksJob's name
 	LabelQJobName = fmt.Sprintf("%s/qjob-name", apis.GroupName)
 	// LabelTriggeringPod key for label, which is set to the UID of the pod that triggered an QuarksJob
@@ -45,6 +92,196 @@ type QuarksJobSpec struct {
 	Trigger              Trigger                 `json:"trigger"`
 	Template             batchv1.JobTemplateSpec `json:"template"`
 	UpdateOnConfigChange bool                    `json:"updateOnConfigChange"`
+	// WatchReferences restricts the referenced config maps and secrets,
+	// whose changes trigger a run if UpdateOnConfigChange is set. Manual
+	// errands are marked stale instead of running.
+	WatchReferences *WatchReferences `json:"watchReferences,omitempty"`
+	Cleanup         *Cleanup         `json:"cleanup,omitempty"`
+	// SuccessfulJobsHistoryLimit is the number of succeeded jobs to keep.
+	// If set, older jobs are pruned instead of deleting the current job.
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+	// FailedJobsHistoryLimit is the number of failed jobs to keep. If
+	// set, older jobs are pruned instead of deleting the current job.
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+	// Retry re-runs failed jobs with an exponential backoff. Failed jobs
+	// are not retried if unset.
+	Retry *Retry `json:"retry,omitempty"`
+	// RunTimeout limits the duration of a run. It's set as the job's
+	// activeDeadlineSeconds and the output-persist container stops waiting
+	// for output, once it's exceeded.
+	RunTimeout *metav1.Duration `json:"runTimeout,omitempty"`
+	// Suspend stops automatic runs, i.e. 'once' and config change
+	// triggers. The latest of them is queued and runs when resumed.
+	// Manual runs are not affected.
+	Suspend bool `json:"suspend,omitempty"`
+	// DependsOn lists QuarksJobs, whose latest run has to succeed before
+	// this QuarksJob runs
+	DependsOn []Dependency `json:"dependsOn,omitempty"`
+	// Priority orders queued runs, while the concurrency limits of the
+	// operator are reached. Higher priorities run first, runs with the
+	// same priority in the order they were queued.
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// WatchReferences selects references in the form 'configmap/<name>' or
+// 'secret/<name>'. Versioned secrets are referenced without the version
+// suffix.
+type WatchReferences struct {
+	// Include lists the only references, whose changes trigger a run. All
+	// references trigger a run if empty.
+	Include []string `json:"include,omitempty"`
+	// Exclude lists references, whose changes never trigger a run
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Watches returns true if changes of the referenced object trigger a run
+func (w *WatchReferences) Watches(kind ReferenceKind, name string) bool {
+	if w == nil {
+		return true
+	}
+
+	refs := []string{Reference(kind, name)}
+	if i := strings.LastIndex(name, "-v"); kind == ReferenceSecret && i > 0 {
+		if _, err := strconv.Atoi(name[i+2:]); err == nil {
+			refs = append(refs, Reference(kind, name[:i]))
+		}
+	}
+	contains := func(list []string) bool {
+		for _, item := range list {
+			for _, ref := range refs {
+				if item == ref {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if contains(w.Exclude) {
+		return false
+	}
+	return len(w.Include) == 0 || contains(w.Include)
+}
+
+// Dependency references another QuarksJob in the same namespace
+type Dependency struct {
+	Name string `json:"name"`
+	// Outputs lists output secrets of the dependency, which have to exist.
+	// Versioned secrets are referenced without the version suffix.
+	Outputs []string `json:"outputs,omitempty"`
+	// Chain runs this QuarksJob again, whenever a run of the dependency
+	// succeeded
+	Chain bool `json:"chain,omitempty"`
+}
+
+// Dependency returns the dependency on the named QuarksJob, nil if there is none
+func (s QuarksJobSpec) Dependency(name string) *Dependency {
+	for i := range s.DependsOn {
+		if s.DependsOn[i].Name == name {
+			return &s.DependsOn[i]
+		}
+	}
+	return nil
+}
+
+// Retry describes when to re-create a job after a failed run
+type Retry struct {
+	// MaxAttempts is the maximum number of runs, including the first one
+	MaxAttempts int32 `json:"maxAttempts"`
+	// Backoff is the delay before the first retry, which doubles for every
+	// further retry up to MaxRetryBackoff. Defaults to DefaultRetryBackoff.
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+	// OnExitCodes restricts retries to runs, in which a container exited
+	// with one of the exit codes
+	OnExitCodes []int32 `json:"onExitCodes,omitempty"`
+	// OnOutputPersistenceFailure restricts retries to runs, in which
+	// persisting the output failed
+	OnOutputPersistenceFailure bool `json:"onOutputPersistenceFailure,omitempty"`
+}
+
+// Retryable returns true if a failed run, with the given non-zero exit
+// codes of the job's containers, should be retried. Any failure is retried,
+// unless OnExitCodes or OnOutputPersistenceFailure restrict it.
+func (r Retry) Retryable(exitCodes []int32, outputPersistenceFailed bool) bool {
+	if len(r.OnExitCodes) == 0 && !r.OnOutputPersistenceFailure {
+		return true
+	}
+	if r.OnOutputPersistenceFailure && outputPersistenceFailed {
+		return true
+	}
+	for _, code := range exitCodes {
+		for _, c := range r.OnExitCodes {
+			if c == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Delay returns the backoff between the failed attempt and the next one
+func (r Retry) Delay(attempt int32) time.Duration {
+	delay := DefaultRetryBackoff
+	if r.Backoff != nil {
+		delay = r.Backoff.Duration
+	}
+	for i := int32(1); i < attempt && delay < MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryBackoff {
+		return MaxRetryBackoff
+	}
+	return delay
+}
+
+// JobsHistoryLimit returns the history limit for succeeded or failed jobs,
+// nil if no history is kept
+func (s QuarksJobSpec) JobsHistoryLimit(succeeded bool) *int32 {
+	if succeeded {
+		return s.SuccessfulJobsHistoryLimit
+	}
+	return s.FailedJobsHistoryLimit
+}
+
+// Cleanup decides what to remove after a job finished
+type Cleanup struct {
+	// OnSuccess applies to succeeded jobs. Defaults to deleting the job,
+	// but keeping its pods.
+	OnSuccess *CleanupPolicy `json:"onSuccess,omitempty"`
+	// OnFailure applies to failed jobs. Defaults to keeping the job and
+	// its pods.
+	OnFailure *CleanupPolicy `json:"onFailure,omitempty"`
+	// TTLSecondsAfterFinished delays the cleanup, it happens immediately
+	// if unset
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// CleanupPolicy lists the resources to delete
+type CleanupPolicy struct {
+	DeleteJob  bool `json:"deleteJob,omitempty"`
+	DeletePods bool `json:"deletePods,omitempty"`
+}
+
+// Policy returns the cleanup policy for a succeeded or failed job
+func (c *Cleanup) Policy(succeeded bool) CleanupPolicy {
+	if succeeded {
+		if c == nil || c.OnSuccess == nil {
+			return CleanupPolicy{DeleteJob: true}
+		}
+		return *c.OnSuccess
+	}
+	if c == nil || c.OnFailure == nil {
+		return CleanupPolicy{}
+	}
+	return *c.OnFailure
+}
+
+// TTL returns the duration to wait after the job finished, before cleaning up
+func (c *Cleanup) TTL() time.Duration {
+	if c == nil || c.TTLSecondsAfterFinished == nil {
+		return 0
+	}
+	return time.Duration(*c.TTLSecondsAfterFinished) * time.Second
 }
 
 // Strategy describes the trigger strategy
@@ -53,6 +290,9 @@ type Strategy string
 // PersistenceMethod describes the secret persistence implemention style
 type PersistenceMethod string
 
+// OutputTarget describes the kind of resource output is persisted to
+type OutputTarget string
+
 const (
 	// RemoteIDKey is the key for the ENV variable which is copied to the
 	// output secrets label `LabelReferencedJobName`
@@ -76,11 +316,116 @@ const (
 	// PersistUsingFanOut results in one secret per key/value pair found in the
 	// provided input file and the name being used as a prefix for the secret
 	PersistUsingFanOut PersistenceMethod = "fan-out"
+
+	// TargetSecret persists output into a secret
+	TargetSecret OutputTarget = "secret"
+	// TargetConfigMap persists output into a config map
+	TargetConfigMap OutputTarget = "configmap"
+
+	// LogKey is the key under which the captured log is stored, unless
+	// the last line is parsed as JSON
+	LogKey = "log"
 )
 
+// TriggerReason describes why a job was started
+type TriggerReason string
+
+const (
+	// TriggerReasonManual jobs were started by setting the trigger strategy to 'now'
+	TriggerReasonManual TriggerReason = "manual"
+	// TriggerReasonOnce jobs are the single run of an auto-errand
+	TriggerReasonOnce TriggerReason = "once"
+	// TriggerReasonConfigChange jobs were started, because referenced config changed
+	TriggerReasonConfigChange TriggerReason = "config-change"
+	// TriggerReasonRetry jobs were started, because the previous attempt failed
+	TriggerReasonRetry TriggerReason = "retry"
+	// TriggerReasonDependency jobs were started, because a chained dependency succeeded
+	TriggerReasonDependency TriggerReason = "dependency"
+	// TriggerReasonAPI jobs were started by the HTTP trigger endpoint
+	TriggerReasonAPI TriggerReason = "api"
+)
+
+const (
+	// DefaultRetryBackoff is the delay before the first retry of a failed job
+	DefaultRetryBackoff = 10 * time.Second
+	// MaxRetryBackoff is the maximum delay between retries of a failed job
+	MaxRetryBackoff = 10 * time.Minute
+)
+
+const (
+	// ConditionCancelled is true if the latest run was cancelled
+	ConditionCancelled = "Cancelled"
+	// ConditionSucceeded is true if the latest run succeeded, false if it
+	// failed and unknown while it's running
+	ConditionSucceeded = "Succeeded"
+	// ConditionStale is true if the referenced configuration of a manual
+	// errand changed since its latest run
+	ConditionStale = "Stale"
+	// ConditionQueued is true while the pending run waits for the
+	// concurrency limits
+	ConditionQueued = "Queued"
+)
+
+// ReferenceKind is the kind of an object referenced by a QuarksJob's template
+type ReferenceKind string
+
+const (
+	// ReferenceConfigMap is the kind of a referenced config map
+	ReferenceConfigMap ReferenceKind = "configmap"
+	// ReferenceSecret is the kind of a referenced secret
+	ReferenceSecret ReferenceKind = "secret"
+	// ReferencePersistentVolumeClaim is the kind of a referenced persistent volume claim
+	ReferencePersistentVolumeClaim ReferenceKind = "persistentvolumeclaim"
+	// ReferenceServiceAccount is the kind of a referenced service account
+	ReferenceServiceAccount ReferenceKind = "serviceaccount"
+)
+
+// Reference formats a reference for QuarksJobStatus.MissingReferences
+func Reference(kind ReferenceKind, name string) string {
+	return fmt.Sprintf("%s/%s", kind, name)
+}
+
+// IsMissingReference returns true if the object is listed in the missing
+// references, either by itself or with one of its keys
+func (s QuarksJobStatus) IsMissingReference(kind ReferenceKind, name string) bool {
+	ref := Reference(kind, name)
+	for _, missing := range s.MissingReferences {
+		if missing == ref || strings.HasPrefix(missing, ref+":") {
+			return true
+		}
+	}
+	return false
+}
+
 // Trigger decides how to trigger the QuarksJob
 type Trigger struct {
 	Strategy Strategy `json:"strategy"`
+	// Debounce overrides the operator's meltdown duration, in which
+	// consecutive triggers are merged into a single run, e.g. '5m' to
+	// coalesce bursty secret rotations. '0s' disables merging.
+	Debounce *metav1.Duration `json:"debounce,omitempty"`
+	// Watch re-runs auto-errands, when a watched resource in the
+	// QuarksJob's namespace changes
+	Watch []WatchTrigger `json:"watch,omitempty"`
+}
+
+// WatchTrigger selects resources of a kind by name or labels. Without name
+// and selector all resources of the kind are watched.
+type WatchTrigger struct {
+	// APIVersion is the group/version of the resource, e.g. 'apps/v1'
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Name       string                `json:"name,omitempty"`
+	Selector   *metav1.LabelSelector `json:"selector,omitempty"`
+	// JSONPath restricts the trigger to changes of the selected fields,
+	// e.g. '{.spec.template.spec.containers[*].image}'. By default all
+	// changes, except to metadata and status, trigger a run.
+	JSONPath string `json:"jsonPath,omitempty"`
+}
+
+// GroupVersionKind returns the watched resource's group, version and kind
+func (w WatchTrigger) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(w.APIVersion, w.Kind)
 }
 
 // SecretOptions specify the name of the output secret and if it's versioned
@@ -90,6 +435,11 @@ type SecretOptions struct {
 	AdditionalSecretAnnotations map[string]string `json:"secretAnnotations,omitempty"`
 	Versioned                   bool              `json:"versioned,omitempty"`
 	PersistenceMethod           PersistenceMethod `json:"persistencemethod,omitempty"`
+	// Optional files are not waited for and skipped if missing
+	Optional bool `json:"optional,omitempty"`
+	// ExposeInStatus lists the keys, whose values are copied to the
+	// QuarksJob's status. Only use this for non-sensitive values.
+	ExposeInStatus []string `json:"exposeInStatus,omitempty"`
 }
 
 // FanOutName returns the name of the secret for PersistenceMethod 'fan-out'
@@ -97,30 +447,123 @@ func (so SecretOptions) FanOutName(key string) string {
 	return so.Name + "-" + key
 }
 
+// StemName returns the name of the secret for a file matched by a pattern,
+// using the file name without extension as a suffix. The suffix is converted
+// to a valid DNS label, e.g. 'CA_Bundle.json' results in '<name>-ca-bundle'.
+func (so SecretOptions) StemName(path string) string {
+	base := filepath.Base(path)
+	return so.Name + "-" + names.DNSLabelSafe(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
 // FilesToSecrets maps file names to secret names
 type FilesToSecrets map[string]SecretOptions
 
 // OutputMap has FilesToSecrets mappings for every container
 type OutputMap map[string]FilesToSecrets
 
+// LogOptions specify how the log of a container is persisted
+type LogOptions struct {
+	Name                        string            `json:"name"`
+	Target                      OutputTarget      `json:"target,omitempty"`
+	TailLines                   *int64            `json:"tailLines,omitempty"`
+	ParseJSON                   bool              `json:"parseJSON,omitempty"`
+	AdditionalSecretLabels      map[string]string `json:"secretLabels,omitempty"`
+	AdditionalSecretAnnotations map[string]string `json:"secretAnnotations,omitempty"`
+}
+
+// LogMap maps container names to the options for persisting their log
+type LogMap map[string]LogOptions
+
 // Output contains options to persist job output to secrets
 type Output struct {
 	// OutputMap allows for for additional output files per container.
 	// Each filename maps to a set of options.
 	OutputMap OutputMap `json:"outputMap"`
 
+	// LogMap captures the stdout/stderr tail of containers, for tools
+	// which can't write their results to a file.
+	LogMap LogMap `json:"logMap,omitempty"`
+
 	// OutputType only JSON is supported for now
 	OutputType string `json:"outputType,omitempty"`
 
 	// SecretLabels are copied onto the newly created secrets
 	SecretLabels   map[string]string `json:"secretLabels,omitempty"`
 	WriteOnFailure bool              `json:"writeOnFailure,omitempty"`
+
+	// Encryption encrypts all output values in the persist-output
+	// container, before they are sent to the API server. Values exposed in
+	// the status are encrypted, too.
+	Encryption *Encryption `json:"encryption,omitempty"`
+
+	// Sidecar overrides the operator defaults for the output-persist
+	// container
+	Sidecar *Sidecar `json:"sidecar,omitempty"`
+}
+
+// Sidecar configures the output-persist container, which is added to the
+// job's pod. Unset fields fall back to the operator defaults.
+type Sidecar struct {
+	Image           string                       `json:"image,omitempty"`
+	ImagePullPolicy corev1.PullPolicy            `json:"imagePullPolicy,omitempty"`
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`
+	SecurityContext *corev1.SecurityContext      `json:"securityContext,omitempty"`
+	// Env is added to the container's environment, the NAMESPACE variable
+	// is reserved
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// Encryption configures client-side encryption of output values
+type Encryption struct {
+	// CertificateRef references a PEM encoded x509 certificate. Output
+	// values are encrypted for its public key using PKCS#7.
+	CertificateRef KeyReference `json:"certificateRef"`
+}
+
+// KeyReference references a key in a ConfigMap or Secret
+type KeyReference struct {
+	// Kind is either ConfigMap or Secret, defaults to ConfigMap
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 // QuarksJobStatus defines the observed state of QuarksJob
 type QuarksJobStatus struct {
 	LastReconcile *metav1.Time `json:"lastReconcile"`
 	Completed     bool         `json:"completed"`
+
+	// MissingReferences lists the objects referenced by the template, which
+	// don't exist. The job is created once they appear.
+	MissingReferences []string `json:"missingReferences,omitempty"`
+	// MissingReferencesSince is the time references were first found to be missing
+	MissingReferencesSince *metav1.Time `json:"missingReferencesSince,omitempty"`
+	// Outputs has the exposed output values, keyed by secret name
+	Outputs map[string]map[string]string `json:"outputs,omitempty"`
+	// PendingRun is the reason of a run, which was triggered while the
+	// QuarksJob was suspended or waiting for its dependencies, by the HTTP
+	// trigger endpoint or by the retry policy
+	PendingRun TriggerReason `json:"pendingRun,omitempty"`
+	// PendingAttempt is the attempt number of a pending retry
+	PendingAttempt int32 `json:"pendingAttempt,omitempty"`
+	// WaitingFor lists the dependencies, which have to succeed before the
+	// pending run starts
+	WaitingFor []string `json:"waitingFor,omitempty"`
+	// Parameters of the run triggered by the HTTP trigger endpoint, they
+	// are passed to the job's containers as environment variables
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ConfigHash is the hash of the referenced configuration of the latest
+	// run
+	ConfigHash string `json:"configHash,omitempty"`
+	// SucceededConfigHash is the hash of the referenced configuration of
+	// the latest successful run. Config changes only trigger a run, if the
+	// hash differs.
+	SucceededConfigHash string `json:"succeededConfigHash,omitempty"`
+	// QueuedSince is set while the pending run waits for the concurrency
+	// limits
+	QueuedSince *metav1.Time `json:"queuedSince,omitempty"`
+	// Conditions describe the state of the latest run
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +genclient
@@ -156,6 +599,11 @@ func (q *QuarksJob) IsAutoErrand() bool {
 	return q.Spec.Trigger.Strategy == TriggerOnce || q.Spec.Trigger.Strategy == TriggerDone
 }
 
+// IsManualErrand returns true if the quarks job is only run on demand
+func (q *QuarksJob) IsManualErrand() bool {
+	return q.Spec.Trigger.Strategy == TriggerManual || q.Spec.Trigger.Strategy == TriggerNow
+}
+
 // GetNamespacedName returns the resource name with its namespace
 func (q *QuarksJob) GetNamespacedName() string {
 	return fmt.Sprintf("%s/%s", q.Namespace, q.Name)
@@ -200,6 +648,12 @@ func NewFileToSecrets(fileName string, secretName string, versioned bool, annota
 	}
 }
 
+// IsPattern returns true if the file name is a glob pattern or a directory,
+// which can match several output files
+func IsPattern(fileName string) bool {
+	return strings.HasSuffix(fileName, "/") || strings.ContainsAny(fileName, "*?[")
+}
+
 // PrefixedPaths returns all output file names, prefixed with the `prefix`
 func (f FilesToSecrets) PrefixedPaths(prefix string) []string {
 	paths := make([]string, 0, len(f))
@@ -208,3 +662,17 @@ func (f FilesToSecrets) PrefixedPaths(prefix string) []string {
 	}
 	return paths
 }
+
+// RequiredPaths returns the output file names, prefixed with the `prefix`,
+// which have to exist before the output can be persisted. Patterns and
+// optional files are not included.
+func (f FilesToSecrets) RequiredPaths(prefix string) []string {
+	paths := make([]string, 0, len(f))
+	for fileName, options := range f {
+		if options.Optional || IsPattern(fileName) {
+			continue
+		}
+		paths = append(paths, filepath.Join(prefix, fileName))
+	}
+	return paths
+}
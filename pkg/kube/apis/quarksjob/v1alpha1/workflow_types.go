@@ -0,0 +1,210 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"code.cloudfoundry.org/quarks-job/pkg/kube/apis"
+)
+
+// This file is safe to edit
+// It's used as input for the Kube code generator
+// Run "make generate" after modifying this file
+
+var (
+	// LabelWorkflowName is the name of the QuarksWorkflow, which created the QuarksJob
+	LabelWorkflowName = fmt.Sprintf("%s/workflow-name", apis.GroupName)
+	// LabelWorkflowStep is the name of the workflow step, which created the QuarksJob
+	LabelWorkflowStep = fmt.Sprintf("%s/workflow-step", apis.GroupName)
+)
+
+// WorkflowInputsMountPath is the directory, in which the output secrets of
+// a step's dependencies are mounted
+const WorkflowInputsMountPath = "/mnt/quarks/inputs"
+
+// QuarksWorkflowSpec defines a DAG of steps, each running a QuarksJob
+type QuarksWorkflowSpec struct {
+	Steps []WorkflowStep `json:"steps"`
+	// FailFast cancels running steps and skips all pending steps, once a
+	// step failed. Otherwise only the steps depending on the failed step
+	// are skipped.
+	FailFast bool `json:"failFast,omitempty"`
+}
+
+// WorkflowStep is a node in the workflow's DAG
+type WorkflowStep struct {
+	Name string `json:"name"`
+	// DependsOn lists the steps, which have to succeed before this step
+	// starts. Steps without dependencies start immediately, steps sharing
+	// a dependency run in parallel.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Template is the spec of the QuarksJob created for the step. Its
+	// trigger strategy is always 'once'.
+	Template QuarksJobSpec `json:"template"`
+}
+
+// OutputSecrets returns the names of the output secrets the step's
+// QuarksJob writes. Versioned and fanned out secrets are not included, as
+// their names are only known after the run.
+func (s WorkflowStep) OutputSecrets() []string {
+	if s.Template.Output == nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, files := range s.Template.Output.OutputMap {
+		for _, options := range files {
+			if options.Versioned || options.PersistenceMethod == PersistUsingFanOut || options.Name == "" {
+				continue
+			}
+			names = append(names, options.Name)
+		}
+	}
+	return names
+}
+
+// QuarksJobName returns the name of the QuarksJob created for the step
+func (w *QuarksWorkflow) QuarksJobName(step string) string {
+	return fmt.Sprintf("%s-%s", w.Name, step)
+}
+
+// Step returns the named step, nil if there is none
+func (w *QuarksWorkflow) Step(name string) *WorkflowStep {
+	for i := range w.Spec.Steps {
+		if w.Spec.Steps[i].Name == name {
+			return &w.Spec.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks the step names are unique and the dependencies form a DAG.
+// It returns the steps in the order they can be started.
+func (w *QuarksWorkflow) Validate() ([]WorkflowStep, error) {
+	if len(w.Spec.Steps) == 0 {
+		return nil, fmt.Errorf("workflow has no steps")
+	}
+
+	steps := map[string]WorkflowStep{}
+	for _, step := range w.Spec.Steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("workflow step has no name")
+		}
+		if _, ok := steps[step.Name]; ok {
+			return nil, fmt.Errorf("workflow step '%s' is defined twice", step.Name)
+		}
+		steps[step.Name] = step
+	}
+	for _, step := range w.Spec.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return nil, fmt.Errorf("workflow step '%s' depends on unknown step '%s'", step.Name, dep)
+			}
+		}
+	}
+
+	// Kahn's algorithm, keeping the order of the spec for independent steps
+	ordered := []WorkflowStep{}
+	done := map[string]bool{}
+	for len(ordered) < len(w.Spec.Steps) {
+		progress := false
+		for _, step := range w.Spec.Steps {
+			if done[step.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range step.DependsOn {
+				ready = ready && done[dep]
+			}
+			if ready {
+				ordered = append(ordered, step)
+				done[step.Name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, fmt.Errorf("workflow steps have a dependency cycle")
+		}
+	}
+	return ordered, nil
+}
+
+// WorkflowPhase is the state of a workflow or one of its steps
+type WorkflowPhase string
+
+const (
+	// WorkflowPending steps wait for their dependencies
+	WorkflowPending WorkflowPhase = "Pending"
+	// WorkflowRunning workflows have unfinished steps, running steps have a QuarksJob
+	WorkflowRunning WorkflowPhase = "Running"
+	// WorkflowSucceeded workflows and steps finished successfully
+	WorkflowSucceeded WorkflowPhase = "Succeeded"
+	// WorkflowFailed workflows have a failed step, failed steps' QuarksJob failed
+	WorkflowFailed WorkflowPhase = "Failed"
+	// WorkflowSkipped steps did not run, because a dependency failed or the
+	// workflow failed fast
+	WorkflowSkipped WorkflowPhase = "Skipped"
+	// WorkflowCancelled steps were cancelled while running
+	WorkflowCancelled WorkflowPhase = "Cancelled"
+)
+
+// Finished returns true for phases, which don't change anymore
+func (p WorkflowPhase) Finished() bool {
+	return p == WorkflowSucceeded || p == WorkflowFailed || p == WorkflowSkipped || p == WorkflowCancelled
+}
+
+// QuarksWorkflowStatus has the status of the workflow and each step
+type QuarksWorkflowStatus struct {
+	Phase WorkflowPhase `json:"phase,omitempty"`
+	// Message explains failed workflows
+	Message    string               `json:"message,omitempty"`
+	StartedAt  *metav1.Time         `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time         `json:"finishedAt,omitempty"`
+	Steps      []WorkflowStepStatus `json:"steps,omitempty"`
+}
+
+// WorkflowStepStatus is the status of a single step
+type WorkflowStepStatus struct {
+	Name      string        `json:"name"`
+	Phase     WorkflowPhase `json:"phase"`
+	QuarksJob string        `json:"quarksJob,omitempty"`
+	Message   string        `json:"message,omitempty"`
+}
+
+// StepStatus returns the status of the named step, nil if there is none
+func (s QuarksWorkflowStatus) StepStatus(name string) *WorkflowStepStatus {
+	for i := range s.Steps {
+		if s.Steps[i].Name == name {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// QuarksWorkflow is the schema for a DAG of QuarksJobs
+// +k8s:openapi-gen=true
+type QuarksWorkflow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   QuarksWorkflowSpec   `json:"spec,omitempty"`
+	Status QuarksWorkflowStatus `json:"status,omitempty"`
+}
+
+// GetNamespacedName returns the resource name with its namespace
+func (w *QuarksWorkflow) GetNamespacedName() string {
+	return fmt.Sprintf("%s/%s", w.Namespace, w.Name)
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// QuarksWorkflowList contains a list of QuarksWorkflow
+type QuarksWorkflowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []QuarksWorkflow `json:"items"`
+}
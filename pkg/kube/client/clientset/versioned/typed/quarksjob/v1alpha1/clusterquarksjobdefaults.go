@@ -0,0 +1,157 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	scheme "code.cloudfoundry.org/quarks-job/pkg/kube/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ClusterQuarksJobDefaultsesGetter has a method to return a ClusterQuarksJobDefaultsInterface.
+// A group's client should implement this interface.
+type ClusterQuarksJobDefaultsesGetter interface {
+	ClusterQuarksJobDefaultses() ClusterQuarksJobDefaultsInterface
+}
+
+// ClusterQuarksJobDefaultsInterface has methods to work with ClusterQuarksJobDefaults resources.
+type ClusterQuarksJobDefaultsInterface interface {
+	Create(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.CreateOptions) (*v1alpha1.ClusterQuarksJobDefaults, error)
+	Update(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.UpdateOptions) (*v1alpha1.ClusterQuarksJobDefaults, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.ClusterQuarksJobDefaults, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.ClusterQuarksJobDefaultsList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClusterQuarksJobDefaults, err error)
+	ClusterQuarksJobDefaultsExpansion
+}
+
+// clusterQuarksJobDefaultses implements ClusterQuarksJobDefaultsInterface
+type clusterQuarksJobDefaultses struct {
+	client rest.Interface
+}
+
+// newClusterQuarksJobDefaultses returns a ClusterQuarksJobDefaultses
+func newClusterQuarksJobDefaultses(c *QuarksjobV1alpha1Client) *clusterQuarksJobDefaultses {
+	return &clusterQuarksJobDefaultses{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the clusterQuarksJobDefaults, and returns the corresponding clusterQuarksJobDefaults object, and an error if there is any.
+func (c *clusterQuarksJobDefaultses) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	result = &v1alpha1.ClusterQuarksJobDefaults{}
+	err = c.client.Get().
+		Resource("clusterquarksjobdefaultses").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ClusterQuarksJobDefaultses that match those selectors.
+func (c *clusterQuarksJobDefaultses) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ClusterQuarksJobDefaultsList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ClusterQuarksJobDefaultsList{}
+	err = c.client.Get().
+		Resource("clusterquarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested clusterQuarksJobDefaultses.
+func (c *clusterQuarksJobDefaultses) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("clusterquarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a clusterQuarksJobDefaults and creates it.  Returns the server's representation of the clusterQuarksJobDefaults, and an error, if there is any.
+func (c *clusterQuarksJobDefaultses) Create(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.CreateOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	result = &v1alpha1.ClusterQuarksJobDefaults{}
+	err = c.client.Post().
+		Resource("clusterquarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(clusterQuarksJobDefaults).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a clusterQuarksJobDefaults and updates it. Returns the server's representation of the clusterQuarksJobDefaults, and an error, if there is any.
+func (c *clusterQuarksJobDefaultses) Update(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.UpdateOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	result = &v1alpha1.ClusterQuarksJobDefaults{}
+	err = c.client.Put().
+		Resource("clusterquarksjobdefaultses").
+		Name(clusterQuarksJobDefaults.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(clusterQuarksJobDefaults).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the clusterQuarksJobDefaults and deletes it. Returns an error if one occurs.
+func (c *clusterQuarksJobDefaultses) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("clusterquarksjobdefaultses").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *clusterQuarksJobDefaultses) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("clusterquarksjobdefaultses").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched clusterQuarksJobDefaults.
+func (c *clusterQuarksJobDefaultses) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	result = &v1alpha1.ClusterQuarksJobDefaults{}
+	err = c.client.Patch(pt).
+		Resource("clusterquarksjobdefaultses").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,111 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeClusterQuarksJobDefaultses implements ClusterQuarksJobDefaultsInterface
+type FakeClusterQuarksJobDefaultses struct {
+	Fake *FakeQuarksjobV1alpha1
+}
+
+var clusterquarksjobdefaultsesResource = schema.GroupVersionResource{Group: "quarksjob", Version: "v1alpha1", Resource: "clusterquarksjobdefaultses"}
+
+var clusterquarksjobdefaultsesKind = schema.GroupVersionKind{Group: "quarksjob", Version: "v1alpha1", Kind: "ClusterQuarksJobDefaults"}
+
+// Get takes name of the clusterQuarksJobDefaults, and returns the corresponding clusterQuarksJobDefaults object, and an error if there is any.
+func (c *FakeClusterQuarksJobDefaultses) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(clusterquarksjobdefaultsesResource, name), &v1alpha1.ClusterQuarksJobDefaults{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClusterQuarksJobDefaults), err
+}
+
+// List takes label and field selectors, and returns the list of ClusterQuarksJobDefaultses that match those selectors.
+func (c *FakeClusterQuarksJobDefaultses) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.ClusterQuarksJobDefaultsList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(clusterquarksjobdefaultsesResource, clusterquarksjobdefaultsesKind, opts), &v1alpha1.ClusterQuarksJobDefaultsList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.ClusterQuarksJobDefaultsList{ListMeta: obj.(*v1alpha1.ClusterQuarksJobDefaultsList).ListMeta}
+	for _, item := range obj.(*v1alpha1.ClusterQuarksJobDefaultsList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested clusterQuarksJobDefaultses.
+func (c *FakeClusterQuarksJobDefaultses) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(clusterquarksjobdefaultsesResource, opts))
+}
+
+// Create takes the representation of a clusterQuarksJobDefaults and creates it.  Returns the server's representation of the clusterQuarksJobDefaults, and an error, if there is any.
+func (c *FakeClusterQuarksJobDefaultses) Create(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.CreateOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(clusterquarksjobdefaultsesResource, clusterQuarksJobDefaults), &v1alpha1.ClusterQuarksJobDefaults{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClusterQuarksJobDefaults), err
+}
+
+// Update takes the representation of a clusterQuarksJobDefaults and updates it. Returns the server's representation of the clusterQuarksJobDefaults, and an error, if there is any.
+func (c *FakeClusterQuarksJobDefaultses) Update(ctx context.Context, clusterQuarksJobDefaults *v1alpha1.ClusterQuarksJobDefaults, opts v1.UpdateOptions) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(clusterquarksjobdefaultsesResource, clusterQuarksJobDefaults), &v1alpha1.ClusterQuarksJobDefaults{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClusterQuarksJobDefaults), err
+}
+
+// Delete takes name of the clusterQuarksJobDefaults and deletes it. Returns an error if one occurs.
+func (c *FakeClusterQuarksJobDefaultses) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteAction(clusterquarksjobdefaultsesResource, name), &v1alpha1.ClusterQuarksJobDefaults{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeClusterQuarksJobDefaultses) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(clusterquarksjobdefaultsesResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.ClusterQuarksJobDefaultsList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched clusterQuarksJobDefaults.
+func (c *FakeClusterQuarksJobDefaultses) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.ClusterQuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(clusterquarksjobdefaultsesResource, name, pt, data, subresources...), &v1alpha1.ClusterQuarksJobDefaults{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ClusterQuarksJobDefaults), err
+}
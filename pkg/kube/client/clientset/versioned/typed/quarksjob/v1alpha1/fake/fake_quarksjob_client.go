@@ -17,10 +17,22 @@ type FakeQuarksjobV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeQuarksjobV1alpha1) ClusterQuarksJobDefaultses() v1alpha1.ClusterQuarksJobDefaultsInterface {
+	return &FakeClusterQuarksJobDefaultses{c}
+}
+
 func (c *FakeQuarksjobV1alpha1) QuarksJobs(namespace string) v1alpha1.QuarksJobInterface {
 	return &FakeQuarksJobs{c, namespace}
 }
 
+func (c *FakeQuarksjobV1alpha1) QuarksJobDefaultses(namespace string) v1alpha1.QuarksJobDefaultsInterface {
+	return &FakeQuarksJobDefaultses{c, namespace}
+}
+
+func (c *FakeQuarksjobV1alpha1) QuarksWorkflows(namespace string) v1alpha1.QuarksWorkflowInterface {
+	return &FakeQuarksWorkflows{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeQuarksjobV1alpha1) RESTClient() rest.Interface {
@@ -0,0 +1,119 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeQuarksJobDefaultses implements QuarksJobDefaultsInterface
+type FakeQuarksJobDefaultses struct {
+	Fake *FakeQuarksjobV1alpha1
+	ns   string
+}
+
+var quarksjobdefaultsesResource = schema.GroupVersionResource{Group: "quarksjob", Version: "v1alpha1", Resource: "quarksjobdefaultses"}
+
+var quarksjobdefaultsesKind = schema.GroupVersionKind{Group: "quarksjob", Version: "v1alpha1", Kind: "QuarksJobDefaults"}
+
+// Get takes name of the quarksJobDefaults, and returns the corresponding quarksJobDefaults object, and an error if there is any.
+func (c *FakeQuarksJobDefaultses) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(quarksjobdefaultsesResource, c.ns, name), &v1alpha1.QuarksJobDefaults{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksJobDefaults), err
+}
+
+// List takes label and field selectors, and returns the list of QuarksJobDefaultses that match those selectors.
+func (c *FakeQuarksJobDefaultses) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.QuarksJobDefaultsList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(quarksjobdefaultsesResource, quarksjobdefaultsesKind, c.ns, opts), &v1alpha1.QuarksJobDefaultsList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.QuarksJobDefaultsList{ListMeta: obj.(*v1alpha1.QuarksJobDefaultsList).ListMeta}
+	for _, item := range obj.(*v1alpha1.QuarksJobDefaultsList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested quarksJobDefaultses.
+func (c *FakeQuarksJobDefaultses) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(quarksjobdefaultsesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a quarksJobDefaults and creates it.  Returns the server's representation of the quarksJobDefaults, and an error, if there is any.
+func (c *FakeQuarksJobDefaultses) Create(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.CreateOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(quarksjobdefaultsesResource, c.ns, quarksJobDefaults), &v1alpha1.QuarksJobDefaults{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksJobDefaults), err
+}
+
+// Update takes the representation of a quarksJobDefaults and updates it. Returns the server's representation of the quarksJobDefaults, and an error, if there is any.
+func (c *FakeQuarksJobDefaultses) Update(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.UpdateOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(quarksjobdefaultsesResource, c.ns, quarksJobDefaults), &v1alpha1.QuarksJobDefaults{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksJobDefaults), err
+}
+
+// Delete takes name of the quarksJobDefaults and deletes it. Returns an error if one occurs.
+func (c *FakeQuarksJobDefaultses) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(quarksjobdefaultsesResource, c.ns, name), &v1alpha1.QuarksJobDefaults{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeQuarksJobDefaultses) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(quarksjobdefaultsesResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.QuarksJobDefaultsList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched quarksJobDefaults.
+func (c *FakeQuarksJobDefaultses) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksJobDefaults, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(quarksjobdefaultsesResource, c.ns, name, pt, data, subresources...), &v1alpha1.QuarksJobDefaults{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksJobDefaults), err
+}
@@ -0,0 +1,131 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeQuarksWorkflows implements QuarksWorkflowInterface
+type FakeQuarksWorkflows struct {
+	Fake *FakeQuarksjobV1alpha1
+	ns   string
+}
+
+var quarksworkflowsResource = schema.GroupVersionResource{Group: "quarksjob", Version: "v1alpha1", Resource: "quarksworkflows"}
+
+var quarksworkflowsKind = schema.GroupVersionKind{Group: "quarksjob", Version: "v1alpha1", Kind: "QuarksWorkflow"}
+
+// Get takes name of the quarksWorkflow, and returns the corresponding quarksWorkflow object, and an error if there is any.
+func (c *FakeQuarksWorkflows) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(quarksworkflowsResource, c.ns, name), &v1alpha1.QuarksWorkflow{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), err
+}
+
+// List takes label and field selectors, and returns the list of QuarksWorkflows that match those selectors.
+func (c *FakeQuarksWorkflows) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.QuarksWorkflowList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(quarksworkflowsResource, quarksworkflowsKind, c.ns, opts), &v1alpha1.QuarksWorkflowList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.QuarksWorkflowList{ListMeta: obj.(*v1alpha1.QuarksWorkflowList).ListMeta}
+	for _, item := range obj.(*v1alpha1.QuarksWorkflowList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested quarksWorkflows.
+func (c *FakeQuarksWorkflows) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(quarksworkflowsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a quarksWorkflow and creates it.  Returns the server's representation of the quarksWorkflow, and an error, if there is any.
+func (c *FakeQuarksWorkflows) Create(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.CreateOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(quarksworkflowsResource, c.ns, quarksWorkflow), &v1alpha1.QuarksWorkflow{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), err
+}
+
+// Update takes the representation of a quarksWorkflow and updates it. Returns the server's representation of the quarksWorkflow, and an error, if there is any.
+func (c *FakeQuarksWorkflows) Update(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(quarksworkflowsResource, c.ns, quarksWorkflow), &v1alpha1.QuarksWorkflow{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeQuarksWorkflows) UpdateStatus(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (*v1alpha1.QuarksWorkflow, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(quarksworkflowsResource, "status", c.ns, quarksWorkflow), &v1alpha1.QuarksWorkflow{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), err
+}
+
+// Delete takes name of the quarksWorkflow and deletes it. Returns an error if one occurs.
+func (c *FakeQuarksWorkflows) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(quarksworkflowsResource, c.ns, name), &v1alpha1.QuarksWorkflow{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeQuarksWorkflows) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(quarksworkflowsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.QuarksWorkflowList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched quarksWorkflow.
+func (c *FakeQuarksWorkflows) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksWorkflow, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(quarksworkflowsResource, c.ns, name, pt, data, subresources...), &v1alpha1.QuarksWorkflow{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), err
+}
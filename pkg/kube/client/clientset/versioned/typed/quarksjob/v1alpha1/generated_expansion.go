@@ -7,4 +7,10 @@ Don't alter this file, it was generated.
 
 package v1alpha1
 
+type ClusterQuarksJobDefaultsExpansion interface{}
+
 type QuarksJobExpansion interface{}
+
+type QuarksJobDefaultsExpansion interface{}
+
+type QuarksWorkflowExpansion interface{}
@@ -15,7 +15,10 @@ import (
 
 type QuarksjobV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	ClusterQuarksJobDefaultsesGetter
 	QuarksJobsGetter
+	QuarksJobDefaultsesGetter
+	QuarksWorkflowsGetter
 }
 
 // QuarksjobV1alpha1Client is used to interact with features provided by the quarksjob group.
@@ -23,10 +26,22 @@ type QuarksjobV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *QuarksjobV1alpha1Client) ClusterQuarksJobDefaultses() ClusterQuarksJobDefaultsInterface {
+	return newClusterQuarksJobDefaultses(c)
+}
+
 func (c *QuarksjobV1alpha1Client) QuarksJobs(namespace string) QuarksJobInterface {
 	return newQuarksJobs(c, namespace)
 }
 
+func (c *QuarksjobV1alpha1Client) QuarksJobDefaultses(namespace string) QuarksJobDefaultsInterface {
+	return newQuarksJobDefaultses(c, namespace)
+}
+
+func (c *QuarksjobV1alpha1Client) QuarksWorkflows(namespace string) QuarksWorkflowInterface {
+	return newQuarksWorkflows(c, namespace)
+}
+
 // NewForConfig creates a new QuarksjobV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*QuarksjobV1alpha1Client, error) {
 	config := *c
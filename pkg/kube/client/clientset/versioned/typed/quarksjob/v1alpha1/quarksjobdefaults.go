@@ -0,0 +1,167 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	scheme "code.cloudfoundry.org/quarks-job/pkg/kube/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// QuarksJobDefaultsesGetter has a method to return a QuarksJobDefaultsInterface.
+// A group's client should implement this interface.
+type QuarksJobDefaultsesGetter interface {
+	QuarksJobDefaultses(namespace string) QuarksJobDefaultsInterface
+}
+
+// QuarksJobDefaultsInterface has methods to work with QuarksJobDefaults resources.
+type QuarksJobDefaultsInterface interface {
+	Create(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.CreateOptions) (*v1alpha1.QuarksJobDefaults, error)
+	Update(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.UpdateOptions) (*v1alpha1.QuarksJobDefaults, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.QuarksJobDefaults, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.QuarksJobDefaultsList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksJobDefaults, err error)
+	QuarksJobDefaultsExpansion
+}
+
+// quarksJobDefaultses implements QuarksJobDefaultsInterface
+type quarksJobDefaultses struct {
+	client rest.Interface
+	ns     string
+}
+
+// newQuarksJobDefaultses returns a QuarksJobDefaultses
+func newQuarksJobDefaultses(c *QuarksjobV1alpha1Client, namespace string) *quarksJobDefaultses {
+	return &quarksJobDefaultses{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the quarksJobDefaults, and returns the corresponding quarksJobDefaults object, and an error if there is any.
+func (c *quarksJobDefaultses) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	result = &v1alpha1.QuarksJobDefaults{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of QuarksJobDefaultses that match those selectors.
+func (c *quarksJobDefaultses) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.QuarksJobDefaultsList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.QuarksJobDefaultsList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested quarksJobDefaultses.
+func (c *quarksJobDefaultses) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a quarksJobDefaults and creates it.  Returns the server's representation of the quarksJobDefaults, and an error, if there is any.
+func (c *quarksJobDefaultses) Create(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.CreateOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	result = &v1alpha1.QuarksJobDefaults{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(quarksJobDefaults).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a quarksJobDefaults and updates it. Returns the server's representation of the quarksJobDefaults, and an error, if there is any.
+func (c *quarksJobDefaultses) Update(ctx context.Context, quarksJobDefaults *v1alpha1.QuarksJobDefaults, opts v1.UpdateOptions) (result *v1alpha1.QuarksJobDefaults, err error) {
+	result = &v1alpha1.QuarksJobDefaults{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		Name(quarksJobDefaults.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(quarksJobDefaults).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the quarksJobDefaults and deletes it. Returns an error if one occurs.
+func (c *quarksJobDefaultses) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *quarksJobDefaultses) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched quarksJobDefaults.
+func (c *quarksJobDefaultses) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksJobDefaults, err error) {
+	result = &v1alpha1.QuarksJobDefaults{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("quarksjobdefaultses").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,184 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	scheme "code.cloudfoundry.org/quarks-job/pkg/kube/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// QuarksWorkflowsGetter has a method to return a QuarksWorkflowInterface.
+// A group's client should implement this interface.
+type QuarksWorkflowsGetter interface {
+	QuarksWorkflows(namespace string) QuarksWorkflowInterface
+}
+
+// QuarksWorkflowInterface has methods to work with QuarksWorkflow resources.
+type QuarksWorkflowInterface interface {
+	Create(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.CreateOptions) (*v1alpha1.QuarksWorkflow, error)
+	Update(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (*v1alpha1.QuarksWorkflow, error)
+	UpdateStatus(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (*v1alpha1.QuarksWorkflow, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.QuarksWorkflow, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.QuarksWorkflowList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksWorkflow, err error)
+	QuarksWorkflowExpansion
+}
+
+// quarksWorkflows implements QuarksWorkflowInterface
+type quarksWorkflows struct {
+	client rest.Interface
+	ns     string
+}
+
+// newQuarksWorkflows returns a QuarksWorkflows
+func newQuarksWorkflows(c *QuarksjobV1alpha1Client, namespace string) *quarksWorkflows {
+	return &quarksWorkflows{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the quarksWorkflow, and returns the corresponding quarksWorkflow object, and an error if there is any.
+func (c *quarksWorkflows) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	result = &v1alpha1.QuarksWorkflow{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of QuarksWorkflows that match those selectors.
+func (c *quarksWorkflows) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.QuarksWorkflowList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.QuarksWorkflowList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested quarksWorkflows.
+func (c *quarksWorkflows) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a quarksWorkflow and creates it.  Returns the server's representation of the quarksWorkflow, and an error, if there is any.
+func (c *quarksWorkflows) Create(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.CreateOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	result = &v1alpha1.QuarksWorkflow{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(quarksWorkflow).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a quarksWorkflow and updates it. Returns the server's representation of the quarksWorkflow, and an error, if there is any.
+func (c *quarksWorkflows) Update(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	result = &v1alpha1.QuarksWorkflow{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		Name(quarksWorkflow.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(quarksWorkflow).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *quarksWorkflows) UpdateStatus(ctx context.Context, quarksWorkflow *v1alpha1.QuarksWorkflow, opts v1.UpdateOptions) (result *v1alpha1.QuarksWorkflow, err error) {
+	result = &v1alpha1.QuarksWorkflow{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		Name(quarksWorkflow.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(quarksWorkflow).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the quarksWorkflow and deletes it. Returns an error if one occurs.
+func (c *quarksWorkflows) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *quarksWorkflows) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched quarksWorkflow.
+func (c *quarksWorkflows) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.QuarksWorkflow, err error) {
+	result = &v1alpha1.QuarksWorkflow{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("quarksworkflows").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,57 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterQuarksJobDefaultsLister helps list ClusterQuarksJobDefaultses.
+// All objects returned here must be treated as read-only.
+type ClusterQuarksJobDefaultsLister interface {
+	// List lists all ClusterQuarksJobDefaultses in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.ClusterQuarksJobDefaults, err error)
+	// Get retrieves the ClusterQuarksJobDefaults from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.ClusterQuarksJobDefaults, error)
+	ClusterQuarksJobDefaultsListerExpansion
+}
+
+// clusterQuarksJobDefaultsLister implements the ClusterQuarksJobDefaultsLister interface.
+type clusterQuarksJobDefaultsLister struct {
+	indexer cache.Indexer
+}
+
+// NewClusterQuarksJobDefaultsLister returns a new ClusterQuarksJobDefaultsLister.
+func NewClusterQuarksJobDefaultsLister(indexer cache.Indexer) ClusterQuarksJobDefaultsLister {
+	return &clusterQuarksJobDefaultsLister{indexer: indexer}
+}
+
+// List lists all ClusterQuarksJobDefaultses in the indexer.
+func (s *clusterQuarksJobDefaultsLister) List(selector labels.Selector) (ret []*v1alpha1.ClusterQuarksJobDefaults, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ClusterQuarksJobDefaults))
+	})
+	return ret, err
+}
+
+// Get retrieves the ClusterQuarksJobDefaults from the index for a given name.
+func (s *clusterQuarksJobDefaultsLister) Get(name string) (*v1alpha1.ClusterQuarksJobDefaults, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("clusterquarksjobdefaults"), name)
+	}
+	return obj.(*v1alpha1.ClusterQuarksJobDefaults), nil
+}
@@ -7,6 +7,10 @@ Don't alter this file, it was generated.
 
 package v1alpha1
 
+// ClusterQuarksJobDefaultsListerExpansion allows custom methods to be added to
+// ClusterQuarksJobDefaultsLister.
+type ClusterQuarksJobDefaultsListerExpansion interface{}
+
 // QuarksJobListerExpansion allows custom methods to be added to
 // QuarksJobLister.
 type QuarksJobListerExpansion interface{}
@@ -14,3 +18,19 @@ type QuarksJobListerExpansion interface{}
 // QuarksJobNamespaceListerExpansion allows custom methods to be added to
 // QuarksJobNamespaceLister.
 type QuarksJobNamespaceListerExpansion interface{}
+
+// QuarksJobDefaultsListerExpansion allows custom methods to be added to
+// QuarksJobDefaultsLister.
+type QuarksJobDefaultsListerExpansion interface{}
+
+// QuarksJobDefaultsNamespaceListerExpansion allows custom methods to be added to
+// QuarksJobDefaultsNamespaceLister.
+type QuarksJobDefaultsNamespaceListerExpansion interface{}
+
+// QuarksWorkflowListerExpansion allows custom methods to be added to
+// QuarksWorkflowLister.
+type QuarksWorkflowListerExpansion interface{}
+
+// QuarksWorkflowNamespaceListerExpansion allows custom methods to be added to
+// QuarksWorkflowNamespaceLister.
+type QuarksWorkflowNamespaceListerExpansion interface{}
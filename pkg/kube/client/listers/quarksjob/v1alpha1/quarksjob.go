@@ -15,8 +15,10 @@ import (
 )
 
 // QuarksJobLister helps list QuarksJobs.
+// All objects returned here must be treated as read-only.
 type QuarksJobLister interface {
 	// List lists all QuarksJobs in the indexer.
+	// Objects returned here must be treated as read-only.
 	List(selector labels.Selector) (ret []*v1alpha1.QuarksJob, err error)
 	// QuarksJobs returns an object that can list and get QuarksJobs.
 	QuarksJobs(namespace string) QuarksJobNamespaceLister
@@ -47,10 +49,13 @@ func (s *quarksJobLister) QuarksJobs(namespace string) QuarksJobNamespaceLister
 }
 
 // QuarksJobNamespaceLister helps list and get QuarksJobs.
+// All objects returned here must be treated as read-only.
 type QuarksJobNamespaceLister interface {
 	// List lists all QuarksJobs in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
 	List(selector labels.Selector) (ret []*v1alpha1.QuarksJob, err error)
 	// Get retrieves the QuarksJob from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
 	Get(name string) (*v1alpha1.QuarksJob, error)
 	QuarksJobNamespaceListerExpansion
 }
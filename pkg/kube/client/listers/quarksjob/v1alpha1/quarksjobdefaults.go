@@ -0,0 +1,88 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// QuarksJobDefaultsLister helps list QuarksJobDefaultses.
+// All objects returned here must be treated as read-only.
+type QuarksJobDefaultsLister interface {
+	// List lists all QuarksJobDefaultses in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.QuarksJobDefaults, err error)
+	// QuarksJobDefaultses returns an object that can list and get QuarksJobDefaultses.
+	QuarksJobDefaultses(namespace string) QuarksJobDefaultsNamespaceLister
+	QuarksJobDefaultsListerExpansion
+}
+
+// quarksJobDefaultsLister implements the QuarksJobDefaultsLister interface.
+type quarksJobDefaultsLister struct {
+	indexer cache.Indexer
+}
+
+// NewQuarksJobDefaultsLister returns a new QuarksJobDefaultsLister.
+func NewQuarksJobDefaultsLister(indexer cache.Indexer) QuarksJobDefaultsLister {
+	return &quarksJobDefaultsLister{indexer: indexer}
+}
+
+// List lists all QuarksJobDefaultses in the indexer.
+func (s *quarksJobDefaultsLister) List(selector labels.Selector) (ret []*v1alpha1.QuarksJobDefaults, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.QuarksJobDefaults))
+	})
+	return ret, err
+}
+
+// QuarksJobDefaultses returns an object that can list and get QuarksJobDefaultses.
+func (s *quarksJobDefaultsLister) QuarksJobDefaultses(namespace string) QuarksJobDefaultsNamespaceLister {
+	return quarksJobDefaultsNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// QuarksJobDefaultsNamespaceLister helps list and get QuarksJobDefaultses.
+// All objects returned here must be treated as read-only.
+type QuarksJobDefaultsNamespaceLister interface {
+	// List lists all QuarksJobDefaultses in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.QuarksJobDefaults, err error)
+	// Get retrieves the QuarksJobDefaults from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.QuarksJobDefaults, error)
+	QuarksJobDefaultsNamespaceListerExpansion
+}
+
+// quarksJobDefaultsNamespaceLister implements the QuarksJobDefaultsNamespaceLister
+// interface.
+type quarksJobDefaultsNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all QuarksJobDefaultses in the indexer for a given namespace.
+func (s quarksJobDefaultsNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.QuarksJobDefaults, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.QuarksJobDefaults))
+	})
+	return ret, err
+}
+
+// Get retrieves the QuarksJobDefaults from the indexer for a given namespace and name.
+func (s quarksJobDefaultsNamespaceLister) Get(name string) (*v1alpha1.QuarksJobDefaults, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("quarksjobdefaults"), name)
+	}
+	return obj.(*v1alpha1.QuarksJobDefaults), nil
+}
@@ -0,0 +1,88 @@
+/*
+
+Don't alter this file, it was generated.
+
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// QuarksWorkflowLister helps list QuarksWorkflows.
+// All objects returned here must be treated as read-only.
+type QuarksWorkflowLister interface {
+	// List lists all QuarksWorkflows in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.QuarksWorkflow, err error)
+	// QuarksWorkflows returns an object that can list and get QuarksWorkflows.
+	QuarksWorkflows(namespace string) QuarksWorkflowNamespaceLister
+	QuarksWorkflowListerExpansion
+}
+
+// quarksWorkflowLister implements the QuarksWorkflowLister interface.
+type quarksWorkflowLister struct {
+	indexer cache.Indexer
+}
+
+// NewQuarksWorkflowLister returns a new QuarksWorkflowLister.
+func NewQuarksWorkflowLister(indexer cache.Indexer) QuarksWorkflowLister {
+	return &quarksWorkflowLister{indexer: indexer}
+}
+
+// List lists all QuarksWorkflows in the indexer.
+func (s *quarksWorkflowLister) List(selector labels.Selector) (ret []*v1alpha1.QuarksWorkflow, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.QuarksWorkflow))
+	})
+	return ret, err
+}
+
+// QuarksWorkflows returns an object that can list and get QuarksWorkflows.
+func (s *quarksWorkflowLister) QuarksWorkflows(namespace string) QuarksWorkflowNamespaceLister {
+	return quarksWorkflowNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// QuarksWorkflowNamespaceLister helps list and get QuarksWorkflows.
+// All objects returned here must be treated as read-only.
+type QuarksWorkflowNamespaceLister interface {
+	// List lists all QuarksWorkflows in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.QuarksWorkflow, err error)
+	// Get retrieves the QuarksWorkflow from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.QuarksWorkflow, error)
+	QuarksWorkflowNamespaceListerExpansion
+}
+
+// quarksWorkflowNamespaceLister implements the QuarksWorkflowNamespaceLister
+// interface.
+type quarksWorkflowNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all QuarksWorkflows in the indexer for a given namespace.
+func (s quarksWorkflowNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.QuarksWorkflow, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.QuarksWorkflow))
+	})
+	return ret, err
+}
+
+// Get retrieves the QuarksWorkflow from the indexer for a given namespace and name.
+func (s quarksWorkflowNamespaceLister) Get(name string) (*v1alpha1.QuarksWorkflow, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("quarksworkflow"), name)
+	}
+	return obj.(*v1alpha1.QuarksWorkflow), nil
+}
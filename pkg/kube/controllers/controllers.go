@@ -8,15 +8,17 @@ import (
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers/quarksjob"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 )
 
 // Theses funcs construct controllers and add them to the controller-runtime
 // manager. The manager will set fields on the controllers and start them, when
 // itself is started.
-var addToManagerFuncs = []func(context.Context, *config.Config, manager.Manager) error{
+var addToManagerFuncs = []func(context.Context, *config.Config, *operatorconfig.Config, manager.Manager) error{
 	quarksjob.AddErrand,
 	quarksjob.AddJob,
+	quarksjob.AddWorkflow,
 }
 
 var addToSchemes = runtime.SchemeBuilder{
@@ -24,9 +26,9 @@ var addToSchemes = runtime.SchemeBuilder{
 }
 
 // AddToManager adds all Controllers to the Manager
-func AddToManager(ctx context.Context, config *config.Config, m manager.Manager) error {
+func AddToManager(ctx context.Context, config *config.Config, opConfig *operatorconfig.Config, m manager.Manager) error {
 	for _, f := range addToManagerFuncs {
-		if err := f(ctx, config, m); err != nil {
+		if err := f(ctx, config, opConfig, m); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,92 @@
+package quarksjob
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// cancel deletes the running jobs of the quarks job together with their
+// pods, drops its pending run, records the Cancelled condition and removes
+// the cancel annotation
+func (r *ErrandReconciler) cancel(ctx context.Context, qJob *qjv1a1.QuarksJob) error {
+	list := &batchv1.JobList{}
+	err := r.client.List(
+		ctx,
+		list,
+		client.InNamespace(qJob.Namespace),
+		client.MatchingLabels(map[string]string{qjv1a1.LabelQJobName: qJob.Name}),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "listing jobs of quarks job '%s' failed", qJob.GetNamespacedName())
+	}
+
+	cancelled := []string{}
+	for i := range list.Items {
+		job := &list.Items[i]
+		if jobFinished(job) {
+			continue
+		}
+
+		err := r.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "deleting job '%s/%s' failed", job.Namespace, job.Name)
+		}
+		cancelled = append(cancelled, job.Name)
+	}
+
+	message := "No job was running"
+	if len(cancelled) > 0 {
+		message = fmt.Sprintf("Deleted running jobs: %s", strings.Join(cancelled, ", "))
+	}
+	ctxlog.WithEvent(qJob, "Cancelled").Infof(ctx, "Cancelled quarks job '%s': %s", qJob.GetNamespacedName(), message)
+
+	// Drop the pending run. Requeues of the meltdown skip config change
+	// runs for the configuration, which was cancelled.
+	configHash, err := reference.ConfigHash(ctx, r.client, r.store, qJob)
+	if err != nil {
+		ctxlog.Errorf(ctx, "Failed to calculate config hash of job '%s': %s", qJob.GetNamespacedName(), err)
+	} else {
+		qJob.Status.ConfigHash = configHash
+	}
+	qJob.Status.PendingRun = ""
+	qJob.Status.PendingAttempt = 0
+	qJob.Status.WaitingFor = nil
+	qJob.Status.MissingReferences = nil
+	qJob.Status.MissingReferencesSince = nil
+	dequeue(qJob, "Cancelled", "The queued run was cancelled")
+	meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+		Type:               qjv1a1.ConditionCancelled,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: qJob.Generation,
+		Reason:             "CancelRequested",
+		Message:            message,
+	})
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update cancelled condition on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+
+	delete(qJob.Annotations, qjv1a1.AnnotationCancel)
+	switch qJob.Spec.Trigger.Strategy {
+	case qjv1a1.TriggerNow:
+		qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+	case qjv1a1.TriggerOnce:
+		qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+	}
+	if err := r.client.Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to remove cancel annotation from job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package quarksjob
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// QueuedRequeueAfter is the delay before a queued run checks the
+// concurrency limits again, in case the end of a job was missed
+const QueuedRequeueAfter = 1 * time.Minute
+
+// admit checks the concurrency limits of the cluster and the quarks job's
+// namespace. It returns why the run has to wait, or an empty string if it
+// may start.
+func (r *ErrandReconciler) admit(ctx context.Context, qJob *qjv1a1.QuarksJob) (string, error) {
+	if limit := r.opConfig.Concurrency.MaxRunningJobs; limit > 0 {
+		ok, err := r.belowLimit(ctx, qJob, limit, "")
+		if err != nil || !ok {
+			return fmt.Sprintf("Limit of %d running jobs in the cluster reached", limit), err
+		}
+	}
+
+	if r.opConfig.Concurrency.MaxRunningJobsPerNamespace > 0 {
+		limit, err := r.namespaceLimit(ctx, qJob.Namespace)
+		if err != nil {
+			return "", err
+		}
+		if limit > 0 {
+			ok, err := r.belowLimit(ctx, qJob, limit, qJob.Namespace)
+			if err != nil || !ok {
+				return fmt.Sprintf("Limit of %d running jobs in namespace '%s' reached", limit, qJob.Namespace), err
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// belowLimit returns true if there are less running jobs than the limit,
+// leaving a slot for the quarks job after all queued runs ahead of it. An
+// empty namespace checks the whole cluster.
+func (r *ErrandReconciler) belowLimit(ctx context.Context, qJob *qjv1a1.QuarksJob, limit int, namespace string) (bool, error) {
+	running, err := runningJobs(ctx, r.reader, namespace)
+	if err != nil {
+		return false, err
+	}
+	if running >= limit {
+		return false, nil
+	}
+
+	queued, err := queuedQuarksJobs(ctx, r.client, namespace)
+	if err != nil {
+		return false, err
+	}
+	ahead := 0
+	for i := range queued {
+		if queued[i].UID != qJob.UID && queuedBefore(&queued[i], qJob) {
+			ahead++
+		}
+	}
+	return running+ahead < limit, nil
+}
+
+// namespaceLimit returns the limit of running jobs in the namespace, the
+// namespace's annotation overrides the operator setting. An annotation of 0
+// disables the limit for the namespace.
+func (r *ErrandReconciler) namespaceLimit(ctx context.Context, namespace string) (int, error) {
+	limit := r.opConfig.Concurrency.MaxRunningJobsPerNamespace
+
+	ns := &corev1.Namespace{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return 0, errors.Wrapf(err, "getting namespace '%s' failed", namespace)
+	}
+	if value, ok := ns.Annotations[qjv1a1.AnnotationMaxRunningJobs]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			ctxlog.Errorf(ctx, "Ignoring invalid annotation '%s: %s' on namespace '%s'", qjv1a1.AnnotationMaxRunningJobs, value, namespace)
+			return limit, nil
+		}
+		limit = n
+	}
+	return limit, nil
+}
+
+// queue keeps the run pending until a slot of the concurrency limits is free
+func (r *ErrandReconciler) queue(ctx context.Context, qJob *qjv1a1.QuarksJob, reason qjv1a1.TriggerReason, message string) (reconcile.Result, error) {
+	if qJob.Status.QueuedSince == nil {
+		ctxlog.WithEvent(qJob, "Queued").Infof(ctx, "Queued '%s' run of quarks job '%s': %s", reason, qJob.GetNamespacedName(), message)
+		now := metav1.Now()
+		qJob.Status.QueuedSince = &now
+	}
+
+	qJob.Status.PendingRun = reason
+	meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+		Type:               qjv1a1.ConditionQueued,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: qJob.Generation,
+		Reason:             "ConcurrencyLimit",
+		Message:            message,
+	})
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to queue run on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+
+	// Finished jobs requeue the queued runs, this is a fallback
+	return reconcile.Result{RequeueAfter: QueuedRequeueAfter}, nil
+}
+
+// dequeue removes the quarks job from the queue of the concurrency limits
+func dequeue(qJob *qjv1a1.QuarksJob, reason string, message string) {
+	qJob.Status.QueuedSince = nil
+	if meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionQueued) {
+		meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+			Type:               qjv1a1.ConditionQueued,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: qJob.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+}
+
+// queuedBefore returns true if the run of a is ahead of the run of b. Runs
+// with higher priority go first, then the ones which were queued earlier.
+// Runs which aren't queued yet go last.
+func queuedBefore(a, b *qjv1a1.QuarksJob) bool {
+	if a.Spec.Priority != b.Spec.Priority {
+		return a.Spec.Priority > b.Spec.Priority
+	}
+	if a.Status.QueuedSince == nil || b.Status.QueuedSince == nil {
+		return b.Status.QueuedSince == nil && a.Status.QueuedSince != nil
+	}
+	if !a.Status.QueuedSince.Equal(b.Status.QueuedSince) {
+		return a.Status.QueuedSince.Before(b.Status.QueuedSince)
+	}
+	return a.GetNamespacedName() < b.GetNamespacedName()
+}
+
+// isQueued returns true if the quarks job's run waits for the concurrency
+// limits only. Suspended runs and runs waiting for dependencies don't take
+// part in the queue.
+func isQueued(qJob *qjv1a1.QuarksJob) bool {
+	return qJob.Status.QueuedSince != nil && qJob.Status.PendingRun != "" && !qJob.Spec.Suspend && len(qJob.Status.WaitingFor) == 0
+}
+
+// queuedQuarksJobs returns the queued quarks jobs in the namespace, or in
+// the cluster if the namespace is empty, in the order they will run
+func queuedQuarksJobs(ctx context.Context, c client.Client, namespace string) ([]qjv1a1.QuarksJob, error) {
+	list := &qjv1a1.QuarksJobList{}
+	if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrap(err, "listing quarks jobs failed")
+	}
+
+	queued := []qjv1a1.QuarksJob{}
+	for _, qJob := range list.Items {
+		if isQueued(&qJob) {
+			queued = append(queued, qJob)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queuedBefore(&queued[i], &queued[j]) })
+	return queued, nil
+}
+
+// runningJobs counts the unfinished jobs created by quarks jobs in the
+// namespace, or in the cluster if the namespace is empty. It reads from the
+// API server, as the cache may miss jobs which were just created.
+func runningJobs(ctx context.Context, c client.Reader, namespace string) (int, error) {
+	list := &batchv1.JobList{}
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.HasLabels{qjv1a1.LabelQJobName}); err != nil {
+		return 0, errors.Wrap(err, "listing jobs failed")
+	}
+
+	running := 0
+	for i := range list.Items {
+		if !jobFinished(&list.Items[i]) {
+			running++
+		}
+	}
+	return running, nil
+}
+
+// jobFinished returns true if the job succeeded, failed or is being deleted
+func jobFinished(job *batchv1.Job) bool {
+	return !job.GetDeletionTimestamp().IsZero() || job.Status.Succeeded > 0 || jobFailed(job)
+}
+
+// getQueuedReconciles returns reconciliation requests for the queued quarks
+// jobs, which may start once the job finished. With a cluster-wide limit all
+// queued quarks jobs are requeued, otherwise the ones in the job's namespace.
+func getQueuedReconciles(ctx context.Context, c client.Client, opConfig *operatorconfig.Config, job client.Object) ([]reconcile.Request, error) {
+	namespace := job.GetNamespace()
+	if opConfig.Concurrency.MaxRunningJobs > 0 {
+		namespace = ""
+	}
+
+	queued, err := queuedQuarksJobs(ctx, c, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []reconcile.Request{}
+	for _, qJob := range queued {
+		result = append(result, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      qJob.Name,
+				Namespace: qJob.Namespace,
+			}})
+	}
+	return result, nil
+}
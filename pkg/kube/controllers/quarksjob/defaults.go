@@ -0,0 +1,125 @@
+package quarksjob
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// getDefaults returns the QuarksJobDefaults of the namespace followed by the
+// ClusterQuarksJobDefaults, each sorted by name. Earlier entries take
+// precedence.
+func (j jobCreatorImpl) getDefaults(ctx context.Context, namespace string) ([]qjv1a1.QuarksJobDefaultsSpec, error) {
+	specs := []qjv1a1.QuarksJobDefaultsSpec{}
+
+	defaults := &qjv1a1.QuarksJobDefaultsList{}
+	err := j.client.List(ctx, defaults, crc.InNamespace(namespace))
+	if err != nil && !meta.IsNoMatchError(err) {
+		return nil, errors.Wrapf(err, "could not list quarks job defaults in namespace '%s'", namespace)
+	}
+	sort.Slice(defaults.Items, func(i, k int) bool { return defaults.Items[i].Name < defaults.Items[k].Name })
+	for _, d := range defaults.Items {
+		ctxlog.Debugf(ctx, "Applying quarks job defaults '%s/%s'", namespace, d.Name)
+		specs = append(specs, d.Spec)
+	}
+
+	clusterDefaults := &qjv1a1.ClusterQuarksJobDefaultsList{}
+	err = j.client.List(ctx, clusterDefaults)
+	if err != nil && !meta.IsNoMatchError(err) {
+		return nil, errors.Wrap(err, "could not list cluster quarks job defaults")
+	}
+	sort.Slice(clusterDefaults.Items, func(i, k int) bool { return clusterDefaults.Items[i].Name < clusterDefaults.Items[k].Name })
+	for _, d := range clusterDefaults.Items {
+		ctxlog.Debugf(ctx, "Applying cluster quarks job defaults '%s'", d.Name)
+		specs = append(specs, d.Spec)
+	}
+
+	return specs, nil
+}
+
+// mergeDefaults adds the defaults to the pod template, without overwriting
+// any of its values
+func mergeDefaults(defaults qjv1a1.QuarksJobDefaultsSpec, template *corev1.PodTemplateSpec) error {
+	template.Labels = mergeMissing(template.Labels, defaults.Labels)
+	template.Spec.NodeSelector = mergeMissing(template.Spec.NodeSelector, defaults.NodeSelector)
+
+	for _, toleration := range defaults.Tolerations {
+		found := false
+		for _, t := range template.Spec.Tolerations {
+			if apiequality.Semantic.DeepEqual(t, toleration) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			template.Spec.Tolerations = append(template.Spec.Tolerations, toleration)
+		}
+	}
+
+	for _, secret := range defaults.ImagePullSecrets {
+		found := false
+		for _, s := range template.Spec.ImagePullSecrets {
+			if s.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			template.Spec.ImagePullSecrets = append(template.Spec.ImagePullSecrets, secret)
+		}
+	}
+
+	if defaults.Resources != nil {
+		for i, container := range template.Spec.Containers {
+			if len(container.Resources.Requests) == 0 && len(container.Resources.Limits) == 0 {
+				template.Spec.Containers[i].Resources = *defaults.Resources.DeepCopy()
+			}
+		}
+	}
+
+	if len(defaults.SecretLabels) > 0 {
+		labels := map[string]string{}
+		if value, ok := template.Annotations[qjv1a1.AnnotationDefaultSecretLabels]; ok {
+			if err := json.Unmarshal([]byte(value), &labels); err != nil {
+				return errors.Wrapf(err, "failed to parse annotation '%s'", qjv1a1.AnnotationDefaultSecretLabels)
+			}
+		}
+		labels = mergeMissing(labels, defaults.SecretLabels)
+
+		value, err := json.Marshal(labels)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal default secret labels")
+		}
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[qjv1a1.AnnotationDefaultSecretLabels] = string(value)
+	}
+
+	return nil
+}
+
+// mergeMissing adds the entries of src, which are missing in dst
+func mergeMissing(dst map[string]string, src map[string]string) map[string]string {
+	if dst == nil {
+		if len(src) == 0 {
+			return dst
+		}
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+	return dst
+}
@@ -0,0 +1,186 @@
+package quarksjob
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// DependenciesRequeueAfter is the delay before a run waiting for its
+// dependencies checks them again, in case their success was missed
+const DependenciesRequeueAfter = 1 * time.Minute
+
+// unmetDependencies returns the names of the dependencies, whose latest run
+// didn't succeed or whose outputs are missing
+func (r *ErrandReconciler) unmetDependencies(ctx context.Context, qJob *qjv1a1.QuarksJob) ([]string, error) {
+	waitingFor := []string{}
+	for _, dependency := range qJob.Spec.DependsOn {
+		met, err := r.dependencyMet(ctx, qJob.Namespace, dependency)
+		if err != nil {
+			return nil, err
+		}
+		if !met {
+			waitingFor = append(waitingFor, dependency.Name)
+		}
+	}
+	return waitingFor, nil
+}
+
+func (r *ErrandReconciler) dependencyMet(ctx context.Context, namespace string, dependency qjv1a1.Dependency) (bool, error) {
+	upstream := &qjv1a1.QuarksJob{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: dependency.Name, Namespace: namespace}, upstream)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "could not get dependency '%s/%s'", namespace, dependency.Name)
+	}
+	if !meta.IsStatusConditionTrue(upstream.Status.Conditions, qjv1a1.ConditionSucceeded) {
+		return false, nil
+	}
+
+	for _, name := range dependency.Outputs {
+		err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &corev1.Secret{})
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "could not get output '%s/%s' of dependency '%s'", namespace, name, dependency.Name)
+		}
+		if _, err := r.store.Latest(ctx, namespace, name); err != nil {
+			ctxlog.Debugf(ctx, "Output '%s/%s' of dependency '%s' does not exist yet", namespace, name, dependency.Name)
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// waitForDependencies queues the run until the dependencies succeeded
+func (r *ErrandReconciler) waitForDependencies(ctx context.Context, qJob *qjv1a1.QuarksJob, reason qjv1a1.TriggerReason, waitingFor []string) (reconcile.Result, error) {
+	ctxlog.WithEvent(qJob, "WaitingForDependencies").Infof(ctx, "Queued '%s' run of quarks job '%s', waiting for: %s", reason, qJob.GetNamespacedName(), strings.Join(waitingFor, ", "))
+
+	// The job reconciler requeues the run when the dependencies succeeded,
+	// this is a fallback
+	result := reconcile.Result{RequeueAfter: DependenciesRequeueAfter}
+	if qJob.Status.PendingRun == reason && reflect.DeepEqual(qJob.Status.WaitingFor, waitingFor) {
+		return result, nil
+	}
+
+	qJob.Status.PendingRun = reason
+	qJob.Status.WaitingFor = waitingFor
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to queue run on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	return result, nil
+}
+
+// dependencyCycle returns the names of the quarks jobs on a dependency cycle
+// through the quarks job, or nil if there is none. Dependencies which don't
+// exist yet are ignored.
+func (r *ErrandReconciler) dependencyCycle(ctx context.Context, qJob *qjv1a1.QuarksJob) ([]string, error) {
+	visited := map[string]bool{}
+
+	var visit func(path []string, dependsOn []qjv1a1.Dependency) ([]string, error)
+	visit = func(path []string, dependsOn []qjv1a1.Dependency) ([]string, error) {
+		for _, dependency := range dependsOn {
+			if dependency.Name == qJob.Name {
+				return append(path, dependency.Name), nil
+			}
+			if visited[dependency.Name] {
+				continue
+			}
+			visited[dependency.Name] = true
+
+			upstream := &qjv1a1.QuarksJob{}
+			err := r.client.Get(ctx, types.NamespacedName{Name: dependency.Name, Namespace: qJob.Namespace}, upstream)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get dependency '%s/%s'", qJob.Namespace, dependency.Name)
+			}
+
+			cycle, err := visit(append(path, dependency.Name), upstream.Spec.DependsOn)
+			if err != nil || cycle != nil {
+				return cycle, err
+			}
+		}
+		return nil, nil
+	}
+
+	return visit([]string{qJob.Name}, qJob.Spec.DependsOn)
+}
+
+// rejectDependencyCycle drops the run of a quarks job, whose dependencies
+// form a cycle, as it would wait or re-run forever
+func (r *ErrandReconciler) rejectDependencyCycle(ctx context.Context, qJob *qjv1a1.QuarksJob, cycle []string) error {
+	_ = ctxlog.WithEvent(qJob, "DependencyCycle").Errorf(ctx, "Not running quarks job '%s', its dependencies form a cycle: %s", qJob.GetNamespacedName(), strings.Join(cycle, " -> "))
+	if qJob.Status.PendingRun == "" && len(qJob.Status.WaitingFor) == 0 {
+		return nil
+	}
+
+	qJob.Status.PendingRun = ""
+	qJob.Status.PendingAttempt = 0
+	qJob.Status.WaitingFor = nil
+	dequeue(qJob, "DependencyCycle", "The dependencies form a cycle")
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to clear pending run on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// notifyDependents is called after a run of the quarks job succeeded. It
+// removes the quarks job from the dependencies its dependents are waiting
+// for and queues a run of chained dependents.
+func (r *ReconcileJob) notifyDependents(ctx context.Context, qj *qjv1a1.QuarksJob) {
+	list := &qjv1a1.QuarksJobList{}
+	if err := r.client.List(ctx, list, client.InNamespace(qj.Namespace)); err != nil {
+		ctxlog.Errorf(ctx, "Failed to list dependents of quarks job '%s': %s", qj.GetNamespacedName(), err)
+		return
+	}
+
+	for i := range list.Items {
+		dependent := &list.Items[i]
+		dependency := dependent.Spec.Dependency(qj.Name)
+		if dependency == nil || dependent.Name == qj.Name {
+			continue
+		}
+
+		changed := false
+		waitingFor := []string{}
+		for _, name := range dependent.Status.WaitingFor {
+			if name != qj.Name {
+				waitingFor = append(waitingFor, name)
+			}
+		}
+		if len(waitingFor) != len(dependent.Status.WaitingFor) {
+			dependent.Status.WaitingFor = waitingFor
+			changed = true
+		}
+		if dependency.Chain && dependent.Status.PendingRun == "" {
+			dependent.Status.PendingRun = qjv1a1.TriggerReasonDependency
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		ctxlog.Infof(ctx, "Notifying quarks job '%s' of succeeded dependency '%s'", dependent.GetNamespacedName(), qj.Name)
+		if err := r.client.Status().Update(ctx, dependent); err != nil {
+			_ = ctxlog.WithEvent(dependent, "UpdateError").Errorf(ctx, "Failed to update status of dependent quarks job '%s': %s", dependent.GetNamespacedName(), err)
+		}
+	}
+}
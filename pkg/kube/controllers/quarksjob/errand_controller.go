@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -19,6 +20,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
@@ -28,11 +30,11 @@ import (
 
 // AddErrand creates a new QuarksJob controller to start errands, when their
 // trigger strategy matches 'now' or 'once', or their configuration changed.
-func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+func AddErrand(ctx context.Context, config *config.Config, opConfig *operatorconfig.Config, mgr manager.Manager) error {
 	f := controllerutil.SetControllerReference
 	ctx = ctxlog.NewContextWithRecorder(ctx, "errand-reconciler", mgr.GetEventRecorderFor("errand-recorder"))
 	store := vss.NewVersionedSecretStore(mgr.GetClient())
-	r := NewErrandReconciler(ctx, config, mgr, f, store)
+	r := NewErrandReconciler(ctx, config, opConfig, mgr, f, store)
 	c, err := controller.New("errand-controller", mgr, controller.Options{
 		Reconciler:              r,
 		MaxConcurrentReconciles: config.MaxQuarksJobWorkers,
@@ -45,7 +47,11 @@ func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager)
 
 	// Trigger when
 	//  * errand jobs are to be run (Spec.Run changes from `manual` to `now` or the job is created with `now`)
-	//  * auto-errands with UpdateOnConfigChange == true have changed config references
+	//  * errands with UpdateOnConfigChange == true have changed config references,
+	//    manual errands are marked stale
+	//  * the cancel annotation is set
+	//  * a suspended quarks job with a pending run is resumed
+	//  * a run is queued by the HTTP trigger endpoint or a dependency succeeded
 	p := predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
 			qJob := e.Object.(*qjv1a1.QuarksJob)
@@ -73,10 +79,21 @@ func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager)
 
 			enqueueForManualErrand := n.Spec.Trigger.Strategy == qjv1a1.TriggerNow && o.Spec.Trigger.Strategy == qjv1a1.TriggerManual
 
-			// enqueuing for auto-errand when referenced secrets changed
-			enqueueForConfigChange := n.IsAutoErrand() && n.Spec.UpdateOnConfigChange && hasConfigsChanged(o, n)
+			// enqueuing for errands when referenced secrets changed
+			enqueueForConfigChange := n.Spec.UpdateOnConfigChange && hasConfigsChanged(o, n)
 
-			shouldProcessEvent := enqueueForManualErrand || enqueueForConfigChange
+			// enqueuing to terminate the running jobs
+			enqueueForCancel := n.Annotations[qjv1a1.AnnotationCancel] == "true" && o.Annotations[qjv1a1.AnnotationCancel] != "true"
+
+			// enqueuing for runs, which were queued while suspended
+			enqueueForResume := o.Spec.Suspend && !n.Spec.Suspend && n.Status.PendingRun != ""
+
+			// enqueuing for runs, which were triggered by the HTTP trigger
+			// endpoint or whose dependencies succeeded
+			enqueueForPendingRun := !n.Spec.Suspend && n.Status.PendingRun != "" && len(n.Status.WaitingFor) == 0 &&
+				(o.Status.PendingRun == "" || len(o.Status.WaitingFor) > 0)
+
+			shouldProcessEvent := enqueueForManualErrand || enqueueForConfigChange || enqueueForCancel || enqueueForResume || enqueueForPendingRun
 			if shouldProcessEvent {
 				ctxlog.NewPredicateEvent(o).Debug(
 					ctx, e.ObjectNew, qjv1a1.QuarksJobResourceName,
@@ -95,8 +112,16 @@ func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager)
 		return errors.Wrapf(err, "Watching Quarks jobs failed in Errand controller.")
 	}
 
+	// Start watches for the resources in the trigger.watch entries of quarks
+	// jobs, trigger auto errands when the watched fields changed
+	watches := newTriggerWatches(ctx, c, mgr.GetClient(), mgr.GetRESTMapper(), mgr.GetCache(), nsPredicate)
+	err = c.Watch(&source.Kind{Type: &qjv1a1.QuarksJob{}}, watches.handler(), nsPredicate)
+	if err != nil {
+		return errors.Wrapf(err, "Watching Quarks jobs for trigger.watch entries failed in Errand controller.")
+	}
+
 	// Watch config maps referenced by resource QuarksJob,
-	// trigger auto errand if UpdateOnConfigChange=true and config data changed
+	// trigger errand if UpdateOnConfigChange=true and config data changed
 	p = predicate.Funcs{
 		CreateFunc:  func(e event.CreateEvent) bool { return false },
 		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
@@ -132,7 +157,7 @@ func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager)
 	}
 
 	// Watch secrets referenced by resource QuarksJob
-	// trigger auto errand if UpdateOnConfigChange=true and config data changed
+	// trigger errand if UpdateOnConfigChange=true and config data changed
 	p = predicate.Funcs{
 		// Only enqueuing versioned secret which has versionedSecret label
 		CreateFunc: func(e event.CreateEvent) bool {
@@ -175,14 +200,90 @@ func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager)
 
 			return reconciles
 		}), nsPredicate, p)
+	if err != nil {
+		return err
+	}
+
+	// Watch jobs of quarks jobs, start queued runs when a job finished and
+	// freed a slot of the concurrency limits
+	p = predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return false },
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			job := e.Object.(*batchv1.Job)
+			return isEJobJob(job.GetLabels()) && !jobFinished(job)
+		},
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*batchv1.Job)
+			n := e.ObjectNew.(*batchv1.Job)
+			return isEJobJob(n.GetLabels()) && !jobFinished(o) && jobFinished(n)
+		},
+	}
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(
+		func(a client.Object) []reconcile.Request {
+			reconciles, err := getQueuedReconciles(ctx, mgr.GetClient(), opConfig, a)
+			if err != nil {
+				ctxlog.Errorf(ctx, "Failed to calculate reconciles for queued runs after job '%s/%s': %v", a.GetNamespace(), a.GetName(), err)
+			}
+
+			for _, reconciliation := range reconciles {
+				ctxlog.NewMappingEvent(a).Debug(ctx, reconciliation, "QuarksJob", a.GetName(), "job")
+			}
+			return reconciles
+		}), nsPredicate, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching jobs for queued runs failed in Errand controller.")
+	}
+
+	// Watch objects, which are missing for QuarksJobs, create the job when
+	// they or their referenced keys appear
+	p = predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return true },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch o := e.ObjectOld.(type) {
+			case *corev1.ConfigMap:
+				n := e.ObjectNew.(*corev1.ConfigMap)
+				return !reflect.DeepEqual(o.Data, n.Data) || !reflect.DeepEqual(o.BinaryData, n.BinaryData)
+			case *corev1.Secret:
+				n := e.ObjectNew.(*corev1.Secret)
+				return !reflect.DeepEqual(o.Data, n.Data)
+			}
+			return false
+		},
+	}
+	for objType, t := range map[string]client.Object{
+		names.ConfigMap:         &corev1.ConfigMap{},
+		names.Secret:            &corev1.Secret{},
+		"persistentvolumeclaim": &corev1.PersistentVolumeClaim{},
+		"serviceaccount":        &corev1.ServiceAccount{},
+	} {
+		objType := objType
+		err = c.Watch(&source.Kind{Type: t}, handler.EnqueueRequestsFromMapFunc(
+			func(a client.Object) []reconcile.Request {
+				reconciles, err := reference.GetMissingReferenceReconciles(ctx, mgr.GetClient(), a)
+				if err != nil {
+					ctxlog.Errorf(ctx, "Failed to calculate reconciles for missing %s '%s/%s': %v", objType, a.GetNamespace(), a.GetName(), err)
+				}
+
+				for _, reconciliation := range reconciles {
+					ctxlog.NewMappingEvent(a).Debug(ctx, reconciliation, "QuarksJob", a.GetName(), objType)
+				}
+				return reconciles
+			}), nsPredicate, p)
+		if err != nil {
+			return errors.Wrapf(err, "Watching missing %s references failed in Errand controller.", objType)
+		}
+	}
 
-	return err
+	return nil
 }
 
-// hasConfigsChanged return true if object's config references changed
+// hasConfigsChanged return true if object's watched config references changed
 func hasConfigsChanged(oldEJob, newEJob *qjv1a1.QuarksJob) bool {
-	oldConfigMaps, oldSecrets := vss.GetConfigNamesFromSpec(oldEJob.Spec.Template.Spec.Template.Spec)
-	newConfigMaps, newSecrets := vss.GetConfigNamesFromSpec(newEJob.Spec.Template.Spec.Template.Spec)
+	oldConfigMaps, oldSecrets := reference.WatchedConfigNames(oldEJob)
+	newConfigMaps, newSecrets := reference.WatchedConfigNames(newEJob)
 
 	if reflect.DeepEqual(oldConfigMaps, newConfigMaps) && reflect.DeepEqual(oldSecrets, newSecrets) {
 		return false
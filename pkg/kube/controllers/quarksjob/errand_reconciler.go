@@ -2,9 +2,14 @@ package quarksjob
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -12,6 +17,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 	"code.cloudfoundry.org/quarks-utils/pkg/meltdown"
@@ -21,26 +28,35 @@ import (
 var _ reconcile.Reconciler = &ErrandReconciler{}
 
 const (
-	// ReconcileSkipDuration is the duration of merging consecutive triggers.
-	ReconcileSkipDuration = 10 * time.Second
+	// MissingReferencesMinBackoff is the initial delay before retrying to
+	// create a job with missing references
+	MissingReferencesMinBackoff = 5 * time.Second
+	// MissingReferencesMaxBackoff is the maximum delay before retrying to
+	// create a job with missing references. The job is also created, when
+	// the reference appears.
+	MissingReferencesMaxBackoff = 5 * time.Minute
 )
 
 // NewErrandReconciler returns a new reconciler for errand jobs.
 func NewErrandReconciler(
 	ctx context.Context,
 	config *config.Config,
+	opConfig *operatorconfig.Config,
 	mgr manager.Manager,
 	f setOwnerReferenceFunc,
 	store vss.VersionedSecretStore,
 ) reconcile.Reconciler {
-	jc := NewJobCreator(mgr.GetClient(), mgr.GetScheme(), f, config, store)
+	jc := NewJobCreator(mgr.GetClient(), mgr.GetScheme(), f, config, opConfig, store)
 
 	return &ErrandReconciler{
 		ctx:        ctx,
 		client:     mgr.GetClient(),
+		reader:     mgr.GetAPIReader(),
 		config:     config,
+		opConfig:   opConfig,
 		scheme:     mgr.GetScheme(),
 		jobCreator: jc,
+		store:      store,
 	}
 }
 
@@ -48,9 +64,16 @@ func NewErrandReconciler(
 type ErrandReconciler struct {
 	ctx        context.Context
 	client     client.Client
+	reader     client.Reader
 	config     *config.Config
+	opConfig   *operatorconfig.Config
 	scheme     *runtime.Scheme
 	jobCreator JobCreator
+	store      vss.VersionedSecretStore
+
+	// admission serializes checking the concurrency limits and creating
+	// the job, so concurrent workers don't exceed the limits
+	admission sync.Mutex
 }
 
 // Reconcile starts jobs for quarks jobs of the type errand with Run being set to 'now' manually.
@@ -73,53 +96,145 @@ func (r *ErrandReconciler) Reconcile(_ context.Context, request reconcile.Reques
 		return reconcile.Result{}, err
 	}
 
-	if qJob.Status.LastReconcile == nil {
-		now := metav1.Now()
-		qJob.Status.LastReconcile = &now
+	if qJob.Annotations[qjv1a1.AnnotationCancel] == "true" {
+		return reconcile.Result{}, r.cancel(ctx, qJob)
+	}
 
-		err := r.client.Status().Update(ctx, qJob)
-		if err != nil {
-			return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update reconcile timestamp on job '%s' (%v): %s", qJob.GetNamespacedName(), qJob.ResourceVersion, err)
+	if qJob.Spec.Trigger.Strategy == qjv1a1.TriggerManual && qJob.Status.PendingRun == "" && len(qJob.Status.MissingReferences) == 0 {
+		// Manual errands are only run with 'now' or a pending run, which
+		// are kept until the job was created. Config changes don't run
+		// them.
+		return reconcile.Result{}, r.markStale(ctx, qJob)
+	}
+
+	reason := triggerReason(qJob)
+	if qJob.Status.QueuedSince == nil && reason != qjv1a1.TriggerReasonRetry {
+		// Queued runs already passed the meltdown, retries their backoff
+		if result, done, err := r.meltdown(ctx, qJob); done {
+			return result, err
 		}
-		ctxlog.Infof(ctx, "Meltdown started for '%s'", request.NamespacedName)
+	}
 
-		return reconcile.Result{RequeueAfter: ReconcileSkipDuration}, nil
+	configHash, err := reference.ConfigHash(ctx, r.client, r.store, qJob)
+	if err != nil {
+		ctxlog.Errorf(ctx, "Failed to calculate config hash of job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	if reason == qjv1a1.TriggerReasonConfigChange && configUnchanged(qJob, configHash) {
+		return reconcile.Result{}, r.skipRun(ctx, qJob)
 	}
+	qJob.Status.ConfigHash = configHash
 
-	if meltdown.NewWindow(ReconcileSkipDuration, qJob.Status.LastReconcile).Contains(time.Now()) {
-		ctxlog.Infof(ctx, "Meltdown in progress for '%s'", request.NamespacedName)
-		return reconcile.Result{}, nil
+	if qJob.Spec.Suspend && reason != qjv1a1.TriggerReasonManual {
+		return reconcile.Result{}, r.queueRun(ctx, qJob, reason)
 	}
 
-	ctxlog.Infof(ctx, "Meltdown ended for '%s'", request.NamespacedName)
-	qJob.Status.LastReconcile = nil
-	err := r.client.Status().Update(ctx, qJob)
+	cycle, err := r.dependencyCycle(ctx, qJob)
 	if err != nil {
-		return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update reconcile timestamp on job '%s' (%v): %s", qJob.GetNamespacedName(), qJob.ResourceVersion, err)
+		return reconcile.Result{}, ctxlog.WithEvent(qJob, "DependencyError").Errorf(ctx, "Failed to check dependencies of job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	if len(cycle) > 0 {
+		return reconcile.Result{}, r.rejectDependencyCycle(ctx, qJob, cycle)
 	}
 
-	if qJob.Spec.Trigger.Strategy == qjv1a1.TriggerNow {
-		// Set Strategy back to manual for errand jobs.
-		qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
-		if err := r.client.Update(ctx, qJob); err != nil {
-			return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to revert to 'trigger.strategy=manual' on job '%s': %s", qJob.GetNamespacedName(), err)
+	waitingFor, err := r.unmetDependencies(ctx, qJob)
+	if err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qJob, "DependencyError").Errorf(ctx, "Failed to check dependencies of job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	if len(waitingFor) > 0 {
+		return r.waitForDependencies(ctx, qJob, reason, waitingFor)
+	}
+
+	limited := r.opConfig.Concurrency.Enabled()
+	if limited {
+		r.admission.Lock()
+		message, err := r.admit(ctx, qJob)
+		if err != nil {
+			r.admission.Unlock()
+			return reconcile.Result{}, ctxlog.WithEvent(qJob, "ConcurrencyError").Errorf(ctx, "Failed to check concurrency limits of job '%s': %s", qJob.GetNamespacedName(), err)
+		}
+		if message != "" {
+			r.admission.Unlock()
+			return r.queue(ctx, qJob, reason, message)
 		}
 	}
 
-	if retry, err := r.jobCreator.Create(ctx, *qJob); err != nil {
+	if reason != qjv1a1.TriggerReasonAPI && reason != qjv1a1.TriggerReasonRetry {
+		// Parameters are only used by the triggered run and its retries
+		qJob.Status.Parameters = nil
+	}
+	attempt := int32(1)
+	if reason == qjv1a1.TriggerReasonRetry && qJob.Status.PendingAttempt > 1 {
+		attempt = qJob.Status.PendingAttempt
+	}
+
+	missing, err := r.jobCreator.Create(ctx, *qJob, reason, attempt)
+	if limited {
+		r.admission.Unlock()
+	}
+	if err != nil {
 		return reconcile.Result{}, ctxlog.WithEvent(qJob, "CreateJobError").Errorf(ctx, "Failed to create job '%s': %s", qJob.GetNamespacedName(), err)
-	} else if retry {
-		ctxlog.Infof(ctx, "Retrying to create job '%s'", qJob.GetNamespacedName())
+	}
+	if len(missing) > 0 {
+		if err := r.recordMissingReferences(ctx, qJob, missing); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		delay := missingReferencesBackoff(qJob.Status.MissingReferencesSince)
+		ctxlog.Infof(ctx, "Retrying to create job '%s' in %s, missing references: %s", qJob.GetNamespacedName(), delay, strings.Join(missing, ", "))
 		result := reconcile.Result{
 			Requeue:      true,
-			RequeueAfter: time.Second * 5,
+			RequeueAfter: delay,
 		}
 		return result, nil
 	}
 
 	ctxlog.WithEvent(qJob, "CreateJob").Infof(ctx, "Created errand job for '%s'", qJob.GetNamespacedName())
 
-	if qJob.Spec.Trigger.Strategy == qjv1a1.TriggerOnce {
+	qJob.Status.MissingReferences = nil
+	qJob.Status.MissingReferencesSince = nil
+	qJob.Status.PendingRun = ""
+	qJob.Status.PendingAttempt = 0
+	qJob.Status.WaitingFor = nil
+	dequeue(qJob, "Started", "A slot of the concurrency limits was free")
+	if meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionCancelled) {
+		meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+			Type:               qjv1a1.ConditionCancelled,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: qJob.Generation,
+			Reason:             "Started",
+			Message:            "A new run was started",
+		})
+	}
+	if meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionStale) {
+		meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+			Type:               qjv1a1.ConditionStale,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: qJob.Generation,
+			Reason:             "Started",
+			Message:            "A new run was started",
+		})
+	}
+	meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+		Type:               qjv1a1.ConditionSucceeded,
+		Status:             metav1.ConditionUnknown,
+		ObservedGeneration: qJob.Generation,
+		Reason:             "Running",
+		Message:            fmt.Sprintf("Started '%s' run", reason),
+	})
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update status of started job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+
+	switch qJob.Spec.Trigger.Strategy {
+	case qjv1a1.TriggerNow:
+		// Set Strategy back to manual for errand jobs, only once the job
+		// was created, so failed attempts are retried.
+		qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+		if err := r.client.Update(ctx, qJob); err != nil {
+			_ = ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to revert to 'trigger.strategy=manual' on job '%s': %s", qJob.GetNamespacedName(), err)
+			return reconcile.Result{Requeue: false}, nil
+		}
+	case qjv1a1.TriggerOnce:
 		// Traverse Strategy into the final 'done' state.
 		qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
 		if err := r.client.Update(ctx, qJob); err != nil {
@@ -130,3 +245,182 @@ func (r *ErrandReconciler) Reconcile(_ context.Context, request reconcile.Reques
 
 	return reconcile.Result{}, nil
 }
+
+// meltdown merges consecutive triggers of the quarks job into a single run
+// at the end of the meltdown period. It returns true if the reconcile has to
+// stop for the meltdown.
+func (r *ErrandReconciler) meltdown(ctx context.Context, qJob *qjv1a1.QuarksJob) (reconcile.Result, bool, error) {
+	duration := r.config.MeltdownDuration
+	if qJob.Spec.Trigger.Debounce != nil {
+		duration = qJob.Spec.Trigger.Debounce.Duration
+	}
+	if duration <= 0 && qJob.Status.LastReconcile == nil {
+		return reconcile.Result{}, false, nil
+	}
+
+	if qJob.Status.LastReconcile == nil {
+		now := metav1.Now()
+		qJob.Status.LastReconcile = &now
+
+		err := r.client.Status().Update(ctx, qJob)
+		if err != nil {
+			return reconcile.Result{}, true, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update reconcile timestamp on job '%s' (%v): %s", qJob.GetNamespacedName(), qJob.ResourceVersion, err)
+		}
+		ctxlog.Infof(ctx, "Meltdown started for '%s'", qJob.GetNamespacedName())
+
+		return reconcile.Result{RequeueAfter: duration}, true, nil
+	}
+
+	window := meltdown.NewWindow(duration, qJob.Status.LastReconcile)
+	if window.Contains(time.Now()) {
+		ctxlog.Infof(ctx, "Meltdown in progress for '%s'", qJob.GetNamespacedName())
+		// Triggers during the meltdown are merged into a single reconcile at its end
+		requeueAfter := time.Until(window.Start.Add(window.Duration))
+		if r.config.MeltdownRequeueAfter > 0 && r.config.MeltdownRequeueAfter < requeueAfter {
+			requeueAfter = r.config.MeltdownRequeueAfter
+		}
+		return reconcile.Result{RequeueAfter: requeueAfter}, true, nil
+	}
+
+	ctxlog.Infof(ctx, "Meltdown ended for '%s'", qJob.GetNamespacedName())
+	qJob.Status.LastReconcile = nil
+	err := r.client.Status().Update(ctx, qJob)
+	if err != nil {
+		return reconcile.Result{}, true, ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update reconcile timestamp on job '%s' (%v): %s", qJob.GetNamespacedName(), qJob.ResourceVersion, err)
+	}
+	return reconcile.Result{}, false, nil
+}
+
+// triggerReason returns why the errand is run. Manual errands are reset to
+// 'manual' after the first attempt, auto-errands only run again on config
+// changes after reaching 'done'. Queued runs keep their original reason.
+func triggerReason(qJob *qjv1a1.QuarksJob) qjv1a1.TriggerReason {
+	if qJob.Status.PendingRun != "" && qJob.Spec.Trigger.Strategy != qjv1a1.TriggerNow {
+		return qJob.Status.PendingRun
+	}
+	switch qJob.Spec.Trigger.Strategy {
+	case qjv1a1.TriggerOnce:
+		return qjv1a1.TriggerReasonOnce
+	case qjv1a1.TriggerDone:
+		return qjv1a1.TriggerReasonConfigChange
+	default:
+		return qjv1a1.TriggerReasonManual
+	}
+}
+
+// configUnchanged returns true if the referenced configuration didn't change
+// since the latest successful run, or since the start of the latest run if
+// it's still running, or since the latest cancel
+func configUnchanged(qJob *qjv1a1.QuarksJob, configHash string) bool {
+	if configHash == "" {
+		return false
+	}
+	if configHash == qJob.Status.SucceededConfigHash {
+		return true
+	}
+	if configHash != qJob.Status.ConfigHash {
+		return false
+	}
+	if meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionCancelled) {
+		return true
+	}
+	condition := meta.FindStatusCondition(qJob.Status.Conditions, qjv1a1.ConditionSucceeded)
+	return condition != nil && condition.Status == metav1.ConditionUnknown
+}
+
+// skipRun drops a config change run, whose configuration didn't change
+func (r *ErrandReconciler) skipRun(ctx context.Context, qJob *qjv1a1.QuarksJob) error {
+	ctxlog.Infof(ctx, "Skipping run of quarks job '%s', its referenced configuration didn't change", qJob.GetNamespacedName())
+	if qJob.Status.PendingRun == "" {
+		return nil
+	}
+
+	qJob.Status.PendingRun = ""
+	qJob.Status.WaitingFor = nil
+	dequeue(qJob, "Skipped", "The referenced configuration didn't change")
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to clear pending run on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// queueRun remembers a run, which was triggered while the quarks job is
+// suspended. Only the latest trigger is kept.
+func (r *ErrandReconciler) queueRun(ctx context.Context, qJob *qjv1a1.QuarksJob, reason qjv1a1.TriggerReason) error {
+	ctxlog.WithEvent(qJob, "Suspended").Infof(ctx, "Queued '%s' run of suspended quarks job '%s'", reason, qJob.GetNamespacedName())
+	if qJob.Status.PendingRun == reason {
+		return nil
+	}
+
+	qJob.Status.PendingRun = reason
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to queue run on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// markStale records that the referenced configuration of a manual errand
+// changed since its latest run, if it's updated on config changes
+func (r *ErrandReconciler) markStale(ctx context.Context, qJob *qjv1a1.QuarksJob) error {
+	if !qJob.Spec.UpdateOnConfigChange || meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionStale) {
+		return nil
+	}
+
+	configHash, err := reference.ConfigHash(ctx, r.client, r.store, qJob)
+	if err != nil {
+		ctxlog.Errorf(ctx, "Failed to calculate config hash of job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	if configHash != "" && configHash == qJob.Status.ConfigHash {
+		return nil
+	}
+
+	ctxlog.WithEvent(qJob, "Stale").Infof(ctx, "Referenced configuration of manual errand '%s' changed since its latest run", qJob.GetNamespacedName())
+	meta.SetStatusCondition(&qJob.Status.Conditions, metav1.Condition{
+		Type:               qjv1a1.ConditionStale,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: qJob.Generation,
+		Reason:             "ConfigChanged",
+		Message:            "Referenced configuration changed since the latest run",
+	})
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to mark job '%s' as stale: %s", qJob.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// recordMissingReferences stores the missing references in the quarks job's
+// status and emits an event if they changed
+func (r *ErrandReconciler) recordMissingReferences(ctx context.Context, qJob *qjv1a1.QuarksJob, missing []string) error {
+	if reflect.DeepEqual(qJob.Status.MissingReferences, missing) {
+		return nil
+	}
+
+	ctxlog.WarningEvent(ctx, qJob, "MissingReferences", fmt.Sprintf("Waiting for references of '%s': %s", qJob.GetNamespacedName(), strings.Join(missing, ", ")))
+
+	qJob.Status.MissingReferences = missing
+	if qJob.Status.MissingReferencesSince == nil {
+		now := metav1.Now()
+		qJob.Status.MissingReferencesSince = &now
+	}
+	if err := r.client.Status().Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(qJob, "UpdateError").Errorf(ctx, "Failed to update missing references on job '%s': %s", qJob.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// missingReferencesBackoff waits as long as the references have been
+// missing, which doubles the delay on every retry
+func missingReferencesBackoff(since *metav1.Time) time.Duration {
+	if since == nil {
+		return MissingReferencesMinBackoff
+	}
+
+	delay := time.Since(since.Time)
+	if delay < MissingReferencesMinBackoff {
+		return MissingReferencesMinBackoff
+	}
+	if delay > MissingReferencesMaxBackoff {
+		return MissingReferencesMaxBackoff
+	}
+	return delay
+}
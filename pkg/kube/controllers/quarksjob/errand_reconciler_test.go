@@ -10,8 +10,11 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest/observer"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -24,8 +27,12 @@ import (
 	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers"
 	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers/fakes"
 	. "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/quarksjob"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
 	"code.cloudfoundry.org/quarks-job/testing"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
 	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
 )
@@ -37,6 +44,7 @@ var _ = Describe("ErrandReconciler", func() {
 			logs                       *observer.ObservedLogs
 			log                        *zap.SugaredLogger
 			mgr                        *fakes.FakeManager
+			opConfig                   *operatorconfig.Config
 			request                    reconcile.Request
 			reconciler                 reconcile.Reconciler
 			qJob                       qjv1a1.QuarksJob
@@ -81,6 +89,7 @@ var _ = Describe("ErrandReconciler", func() {
 			reconciler = NewErrandReconciler(
 				ctx,
 				config,
+				opConfig,
 				mgr,
 				setOwnerReference,
 				vss.NewVersionedSecretStore(mgr.GetClient()),
@@ -95,6 +104,7 @@ var _ = Describe("ErrandReconciler", func() {
 			err := controllers.AddToScheme(scheme.Scheme)
 			Expect(err).NotTo(HaveOccurred())
 			mgr = &fakes.FakeManager{}
+			opConfig = operatorconfig.NewDefaultConfig()
 			setOwnerReferenceCallCount = 0
 			logs, log = helper.NewTestLogger()
 		})
@@ -111,7 +121,7 @@ var _ = Describe("ErrandReconciler", func() {
 
 				qJobName = "fake-qj"
 				qJob = env.ErrandQuarksJob(qJobName, qJob.Namespace)
-				qJobTime := metav1.NewTime(metav1.Now().Add(ReconcileSkipDuration))
+				qJobTime := metav1.NewTime(metav1.Now().Add(config.MeltdownDuration))
 				qJob.Status.LastReconcile = &qJobTime
 				serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
 				client.GetCalls(clientGetStub)
@@ -149,11 +159,12 @@ var _ = Describe("ErrandReconciler", func() {
 					client.UpdateReturns(fmt.Errorf("fake-error"))
 				})
 
-				It("should return and try to requeue", func() {
-					_, err := act()
-					Expect(err).To(HaveOccurred())
+				It("should log and not requeue, as the job was created", func() {
+					result, err := act()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Requeue).To(BeFalse())
 					Expect(logs.FilterMessageSnippet(fmt.Sprintf("Failed to revert to 'trigger.strategy=manual' on job '/%s': fake-error", qJobName)).Len()).To(Equal(1))
-					Expect(client.CreateCallCount()).To(Equal(0))
+					Expect(client.CreateCallCount()).To(Equal(1))
 				})
 			})
 
@@ -168,6 +179,20 @@ var _ = Describe("ErrandReconciler", func() {
 					Expect(err).To(HaveOccurred())
 					Expect(client.CreateCallCount()).To(Equal(1))
 				})
+
+				It("should keep the manual trigger for the retry", func() {
+					_, err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(client.UpdateCallCount()).To(Equal(0))
+					Expect(qJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerNow))
+
+					client.CreateReturns(nil)
+					_, err = act()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(client.CreateCallCount()).To(Equal(2))
+					_, object, _ := client.UpdateArgsForCall(0)
+					Expect(object.(*qjv1a1.QuarksJob).Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerManual))
+				})
 			})
 
 			Context("when client fails to create jobs because it already exists", func() {
@@ -192,19 +217,33 @@ var _ = Describe("ErrandReconciler", func() {
 			var (
 				client       fakes.FakeClient
 				statusWriter fakes.FakeStatusWriter
+				job          *batchv1.Job
 			)
 
+			BeforeEach(func() {
+				qJob = env.ErrandQuarksJob("fake-qj", qJob.Namespace)
+				qJobTime := metav1.NewTime(metav1.Now().Add(config.MeltdownDuration))
+				qJob.Status.LastReconcile = &qJobTime
+				serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
+				job = nil
+				client = fakes.FakeClient{}
+				statusWriter = fakes.FakeStatusWriter{}
+				mgr.GetClientReturns(&client)
+				client.GetCalls(clientGetStub)
+				client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
+				client.CreateCalls(func(_ context.Context, object crc.Object, _ ...crc.CreateOption) error {
+					if j, ok := object.(*batchv1.Job); ok {
+						job = j
+					}
+					return nil
+				})
+
+				request = newRequest(qJob)
+			})
+
 			Context("meltdown should work", func() {
 				BeforeEach(func() {
-					qJob = env.ErrandQuarksJob("fake-qj", qJob.Namespace)
 					qJob.Status.LastReconcile = nil
-					serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
-					client = fakes.FakeClient{}
-					statusWriter = fakes.FakeStatusWriter{}
-					mgr.GetClientReturns(&client)
-					client.GetCalls(clientGetStub)
-					client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
-					request = newRequest(qJob)
 
 					statusCallQueue := helper.NewCallQueue(
 						func(context context.Context, object crc.Object) error {
@@ -221,7 +260,7 @@ var _ = Describe("ErrandReconciler", func() {
 				It("should go into meltdown", func() {
 					result, err := act()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(result.RequeueAfter).To(Equal(ReconcileSkipDuration))
+					Expect(result.RequeueAfter).To(Equal(config.MeltdownDuration))
 					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
 					Expect(logs.FilterMessageSnippet("Meltdown started for").Len()).To(Equal(1))
 				})
@@ -229,7 +268,7 @@ var _ = Describe("ErrandReconciler", func() {
 				It("should stay in meltown when multiple reconciles happen", func() {
 					result, err := act()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(result.RequeueAfter).To(Equal(ReconcileSkipDuration))
+					Expect(result.RequeueAfter).To(Equal(config.MeltdownDuration))
 					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
 					Expect(logs.FilterMessageSnippet("Meltdown started for").Len()).To(Equal(1))
 					now := metav1.Now()
@@ -238,25 +277,47 @@ var _ = Describe("ErrandReconciler", func() {
 					result, err = act()
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result.Requeue).To(BeFalse())
+					Expect(result.RequeueAfter).To(Equal(config.MeltdownRequeueAfter))
 					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
 					Expect(logs.FilterMessageSnippet("Meltdown in progress").Len()).To(Equal(1))
 				})
-			})
 
-			Context("and the errand is a manual errand", func() {
-				BeforeEach(func() {
-					qJob = env.ErrandQuarksJob("fake-qj", qJob.Namespace)
-					qJobTime := metav1.NewTime(metav1.Now().Add(ReconcileSkipDuration))
-					qJob.Status.LastReconcile = &qJobTime
-					serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
-					client = fakes.FakeClient{}
-					mgr.GetClientReturns(&client)
-					client.GetCalls(clientGetStub)
-					client.StatusCalls(func() crc.StatusWriter { return &fakes.FakeStatusWriter{} })
+				It("should requeue at the end of the meltdown", func() {
+					almostOver := metav1.NewTime(time.Now().Add(5*time.Second - config.MeltdownDuration))
+					qJob.Status.LastReconcile = &almostOver
 
-					request = newRequest(qJob)
+					result, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeNumerically("<=", 5*time.Second))
+					Expect(result.RequeueAfter).To(BeNumerically(">", 0))
 				})
 
+				It("should use the debounce duration of the quarks job", func() {
+					qJob.Spec.Trigger.Debounce = &metav1.Duration{Duration: 5 * time.Minute}
+
+					result, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+
+					tenSecondsAgo := metav1.NewTime(time.Now().Add(-10 * time.Second))
+					qJob.Status.LastReconcile = &tenSecondsAgo
+					_, err = act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(logs.FilterMessageSnippet("Meltdown in progress").Len()).To(Equal(1))
+				})
+
+				It("should skip the meltdown if debouncing is disabled", func() {
+					qJob.Spec.Trigger.Debounce = &metav1.Duration{}
+					statusWriter.UpdateCalls(nil)
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(logs.FilterMessageSnippet("Meltdown started for").Len()).To(Equal(0))
+					Expect(client.CreateCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("and the errand is a manual errand", func() {
 				It("should set run back and create a job", func() {
 					Expect(qJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerNow))
 
@@ -275,20 +336,557 @@ var _ = Describe("ErrandReconciler", func() {
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result.Requeue).To(BeFalse())
 				})
+
+				It("should limit the run with the run timeout", func() {
+					qJob.Spec.RunTimeout = &metav1.Duration{Duration: 90500 * time.Millisecond}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(job.Spec.ActiveDeadlineSeconds).To(Equal(pointers.Int64(91)))
+				})
+
+				It("should mark a cancelled run as started again", func() {
+					statusWriter := &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+					qJob.Status.Conditions = []metav1.Condition{
+						{Type: qjv1a1.ConditionCancelled, Status: metav1.ConditionTrue, Reason: "CancelRequested"},
+					}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					condition := meta.FindStatusCondition(object.(*qjv1a1.QuarksJob).Status.Conditions, qjv1a1.ConditionCancelled)
+					Expect(condition).ToNot(BeNil())
+					Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+					Expect(condition.Reason).To(Equal("Started"))
+				})
+
+				Context("when the referenced config of the manual errand changed", func() {
+					BeforeEach(func() {
+						statusWriter = fakes.FakeStatusWriter{}
+						client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+						qJob.Spec.UpdateOnConfigChange = true
+					})
+
+					It("marks the errand as stale instead of running it", func() {
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+						_, object, _ := statusWriter.UpdateArgsForCall(0)
+						condition := meta.FindStatusCondition(object.(*qjv1a1.QuarksJob).Status.Conditions, qjv1a1.ConditionStale)
+						Expect(condition).ToNot(BeNil())
+						Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+						Expect(condition.Reason).To(Equal("ConfigChanged"))
+					})
+
+					It("doesn't mark errands, which aren't updated on config changes", func() {
+						qJob.Spec.UpdateOnConfigChange = false
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+					})
+
+					It("doesn't update an errand, which is already stale", func() {
+						qJob.Status.Conditions = []metav1.Condition{
+							{Type: qjv1a1.ConditionStale, Status: metav1.ConditionTrue, Reason: "ConfigChanged"},
+						}
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+					})
+
+					It("clears the stale condition when the errand runs", func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerNow
+						qJob.Status.Conditions = []metav1.Condition{
+							{Type: qjv1a1.ConditionStale, Status: metav1.ConditionTrue, Reason: "ConfigChanged"},
+						}
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						condition := meta.FindStatusCondition(object.(*qjv1a1.QuarksJob).Status.Conditions, qjv1a1.ConditionStale)
+						Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+						Expect(condition.Reason).To(Equal("Started"))
+					})
+				})
+
+				Context("when the concurrency limits are reached", func() {
+					var (
+						runningJobs []batchv1.Job
+						queued      []qjv1a1.QuarksJob
+					)
+
+					BeforeEach(func() {
+						opConfig.Concurrency.MaxRunningJobsPerNamespace = 1
+						mgr.GetAPIReaderReturns(&client)
+						statusWriter = fakes.FakeStatusWriter{}
+						client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
+						qJob.UID = "this-uid"
+						runningJobs = []batchv1.Job{{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: qJob.Namespace}}}
+						queued = []qjv1a1.QuarksJob{}
+						client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+							switch list := object.(type) {
+							case *batchv1.JobList:
+								list.Items = runningJobs
+							case *qjv1a1.QuarksJobList:
+								list.Items = queued
+							}
+							return nil
+						})
+					})
+
+					AfterEach(func() {
+						delete(namespace.Annotations, qjv1a1.AnnotationMaxRunningJobs)
+					})
+
+					It("queues the run", func() {
+						result, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.RequeueAfter).To(Equal(QueuedRequeueAfter))
+						Expect(client.CreateCallCount()).To(Equal(0))
+
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						status := object.(*qjv1a1.QuarksJob).Status
+						Expect(status.PendingRun).To(Equal(qjv1a1.TriggerReasonManual))
+						Expect(status.QueuedSince).ToNot(BeNil())
+						condition := meta.FindStatusCondition(status.Conditions, qjv1a1.ConditionQueued)
+						Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+						Expect(condition.Message).To(HavePrefix("Limit of 1 running jobs in namespace"))
+					})
+
+					It("queues the run for the cluster-wide limit", func() {
+						opConfig.Concurrency = operatorconfig.Concurrency{MaxRunningJobs: 1}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("Limit of 1 running jobs in the cluster reached").Len()).To(Equal(1))
+					})
+
+					It("creates the job without checking, if no limit is set", func() {
+						opConfig.Concurrency = operatorconfig.Concurrency{}
+						namespace.Annotations = map[string]string{qjv1a1.AnnotationMaxRunningJobs: "1"}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+						for i := 0; i < client.ListCallCount(); i++ {
+							_, object, _ := client.ListArgsForCall(i)
+							Expect(object).ToNot(BeAssignableToTypeOf(&batchv1.JobList{}))
+						}
+					})
+
+					It("uses the limit of the namespace's annotation", func() {
+						namespace.Annotations = map[string]string{qjv1a1.AnnotationMaxRunningJobs: "2"}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+					})
+
+					It("queues a pending retry", func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+						qJob.Status.LastReconcile = nil
+						qJob.Status.PendingRun = qjv1a1.TriggerReasonRetry
+						qJob.Status.PendingAttempt = 2
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						status := object.(*qjv1a1.QuarksJob).Status
+						Expect(status.PendingRun).To(Equal(qjv1a1.TriggerReasonRetry))
+						Expect(status.PendingAttempt).To(Equal(int32(2)))
+						Expect(status.QueuedSince).ToNot(BeNil())
+					})
+
+					It("doesn't count finished jobs", func() {
+						runningJobs[0].Status.Succeeded = 1
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+					})
+
+					Context("when a slot is free", func() {
+						BeforeEach(func() {
+							runningJobs = nil
+							since := metav1.NewTime(time.Now().Add(-time.Minute))
+							qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+							qJob.Status.LastReconcile = nil
+							qJob.Status.PendingRun = qjv1a1.TriggerReasonConfigChange
+							qJob.Status.QueuedSince = &since
+							qJob.Status.Conditions = []metav1.Condition{{Type: qjv1a1.ConditionQueued, Status: metav1.ConditionTrue, Reason: "ConcurrencyLimit"}}
+						})
+
+						It("starts the queued run without meltdown", func() {
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(client.CreateCallCount()).To(Equal(1))
+
+							_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+							status := object.(*qjv1a1.QuarksJob).Status
+							Expect(status.PendingRun).To(BeEmpty())
+							Expect(status.QueuedSince).To(BeNil())
+							Expect(meta.IsStatusConditionFalse(status.Conditions, qjv1a1.ConditionQueued)).To(BeTrue())
+						})
+
+						It("starts a pending retry without meltdown", func() {
+							qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+							qJob.Status.PendingRun = qjv1a1.TriggerReasonRetry
+							qJob.Status.PendingAttempt = 2
+							qJob.Status.QueuedSince = nil
+
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(client.CreateCallCount()).To(Equal(1))
+							_, object, _ := client.CreateArgsForCall(0)
+							Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationTriggerReason, string(qjv1a1.TriggerReasonRetry)))
+							Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationAttempt, "2"))
+
+							_, object, _ = statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+							status := object.(*qjv1a1.QuarksJob).Status
+							Expect(status.PendingRun).To(BeEmpty())
+							Expect(status.PendingAttempt).To(BeZero())
+						})
+
+						It("waits for queued runs with higher priority", func() {
+							earlier := metav1.NewTime(time.Now().Add(-time.Hour))
+							queued = []qjv1a1.QuarksJob{{
+								ObjectMeta: metav1.ObjectMeta{Name: "important", Namespace: qJob.Namespace, UID: "other-uid"},
+								Spec:       qjv1a1.QuarksJobSpec{Priority: 10},
+								Status:     qjv1a1.QuarksJobStatus{PendingRun: qjv1a1.TriggerReasonOnce, QueuedSince: &earlier},
+							}}
+
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(client.CreateCallCount()).To(Equal(0))
+						})
+
+						It("runs before queued runs with lower priority", func() {
+							earlier := metav1.NewTime(time.Now().Add(-time.Hour))
+							queued = []qjv1a1.QuarksJob{{
+								ObjectMeta: metav1.ObjectMeta{Name: "unimportant", Namespace: qJob.Namespace, UID: "other-uid"},
+								Spec:       qjv1a1.QuarksJobSpec{Priority: -1},
+								Status:     qjv1a1.QuarksJobStatus{PendingRun: qjv1a1.TriggerReasonOnce, QueuedSince: &earlier},
+							}}
+
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(client.CreateCallCount()).To(Equal(1))
+						})
+
+						It("waits for runs, which were queued earlier", func() {
+							earlier := metav1.NewTime(time.Now().Add(-time.Hour))
+							queued = []qjv1a1.QuarksJob{{
+								ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: qJob.Namespace, UID: "other-uid"},
+								Status:     qjv1a1.QuarksJobStatus{PendingRun: qjv1a1.TriggerReasonOnce, QueuedSince: &earlier},
+							}}
+
+							_, err := act()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(client.CreateCallCount()).To(Equal(0))
+						})
+					})
+				})
+
+				Context("when the quarks job depends on other quarks jobs", func() {
+					BeforeEach(func() {
+						statusWriter = fakes.FakeStatusWriter{}
+						client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
+						qJob.Spec.DependsOn = []qjv1a1.Dependency{{Name: "upstream"}}
+					})
+
+					It("waits until the dependencies succeeded", func() {
+						result, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.RequeueAfter).To(Equal(DependenciesRequeueAfter))
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("Queued 'manual' run of quarks job '/fake-qj', waiting for: upstream").Len()).To(Equal(1))
+
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						status := object.(*qjv1a1.QuarksJob).Status
+						Expect(status.PendingRun).To(Equal(qjv1a1.TriggerReasonManual))
+						Expect(status.WaitingFor).To(Equal([]string{"upstream"}))
+					})
+
+					It("rejects a dependency on itself", func() {
+						qJob.Spec.DependsOn = []qjv1a1.Dependency{{Name: "fake-qj", Chain: true}}
+						qJob.Status.PendingRun = qjv1a1.TriggerReasonDependency
+
+						result, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.RequeueAfter).To(BeZero())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("Not running quarks job '/fake-qj', its dependencies form a cycle: fake-qj -> fake-qj").Len()).To(Equal(1))
+
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						Expect(object.(*qjv1a1.QuarksJob).Status.PendingRun).To(BeEmpty())
+					})
+
+					It("rejects dependency cycles through other quarks jobs", func() {
+						client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+							if upstream, ok := obj.(*qjv1a1.QuarksJob); ok && nn.Name == "upstream" {
+								upstream.Name = "upstream"
+								upstream.Spec.DependsOn = []qjv1a1.Dependency{{Name: "fake-qj", Chain: true}}
+								return nil
+							}
+							return clientGetStub(ctx, nn, obj)
+						})
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("its dependencies form a cycle: fake-qj -> upstream -> fake-qj").Len()).To(Equal(1))
+					})
+
+					It("waits for the outputs of the dependencies", func() {
+						qJob.Status.Conditions = []metav1.Condition{{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionTrue, Reason: "JobSucceeded"}}
+						qJob.Spec.DependsOn[0].Outputs = []string{"upstream-output"}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+					})
+
+					It("creates the job once the dependencies succeeded", func() {
+						qJob.Status.Conditions = []metav1.Condition{{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionTrue, Reason: "JobSucceeded"}}
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+						qJob.Status.PendingRun = qjv1a1.TriggerReasonDependency
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+						_, object, _ := client.CreateArgsForCall(0)
+						Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationTriggerReason, string(qjv1a1.TriggerReasonDependency)))
+
+						_, object, _ = statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						status := object.(*qjv1a1.QuarksJob).Status
+						Expect(status.PendingRun).To(BeEmpty())
+						Expect(status.WaitingFor).To(BeEmpty())
+						Expect(meta.FindStatusCondition(status.Conditions, qjv1a1.ConditionSucceeded).Status).To(Equal(metav1.ConditionUnknown))
+					})
+				})
+
+				Context("when the quarks job is cancelled", func() {
+					var (
+						statusWriter *fakes.FakeStatusWriter
+						jobs         []batchv1.Job
+					)
+
+					BeforeEach(func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerManual
+						qJob.Annotations = map[string]string{qjv1a1.AnnotationCancel: "true"}
+						jobs = []batchv1.Job{
+							{ObjectMeta: metav1.ObjectMeta{Name: "fake-qj-running"}},
+							{ObjectMeta: metav1.ObjectMeta{Name: "fake-qj-succeeded"}, Status: batchv1.JobStatus{Succeeded: 1}},
+						}
+
+						statusWriter = &fakes.FakeStatusWriter{}
+						client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+						client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+							if list, ok := object.(*batchv1.JobList); ok {
+								list.Items = jobs
+							}
+							return nil
+						})
+					})
+
+					It("deletes the running jobs and records the condition", func() {
+						result, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.Requeue).To(BeFalse())
+						Expect(client.CreateCallCount()).To(Equal(0))
+
+						Expect(client.DeleteCallCount()).To(Equal(1))
+						_, object, opts := client.DeleteArgsForCall(0)
+						Expect(object.GetName()).To(Equal("fake-qj-running"))
+						Expect(opts).To(ContainElement(crc.PropagationPolicy(metav1.DeletePropagationBackground)))
+
+						Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+						_, object, _ = statusWriter.UpdateArgsForCall(0)
+						conditions := object.(*qjv1a1.QuarksJob).Status.Conditions
+						Expect(conditions).To(HaveLen(1))
+						Expect(conditions[0].Type).To(Equal(qjv1a1.ConditionCancelled))
+						Expect(conditions[0].Status).To(Equal(metav1.ConditionTrue))
+						Expect(conditions[0].Message).To(Equal("Deleted running jobs: fake-qj-running"))
+
+						Expect(client.UpdateCallCount()).To(Equal(1))
+						_, object, _ = client.UpdateArgsForCall(0)
+						Expect(object.GetAnnotations()).ToNot(HaveKey(qjv1a1.AnnotationCancel))
+						Expect(object.(*qjv1a1.QuarksJob).Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerManual))
+					})
+
+					It("records the condition if no job was running", func() {
+						jobs = jobs[1:]
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.DeleteCallCount()).To(Equal(0))
+						_, object, _ := statusWriter.UpdateArgsForCall(0)
+						Expect(object.(*qjv1a1.QuarksJob).Status.Conditions[0].Message).To(Equal("No job was running"))
+					})
+
+					It("drops the pending run, so it isn't started after the meltdown", func() {
+						jobs = nil
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerOnce
+						qJob.Status.PendingRun = qjv1a1.TriggerReasonAPI
+						qJob.Status.WaitingFor = []string{"upstream"}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						_, object, _ := statusWriter.UpdateArgsForCall(0)
+						status := object.(*qjv1a1.QuarksJob).Status
+						Expect(status.PendingRun).To(BeEmpty())
+						Expect(status.WaitingFor).To(BeEmpty())
+						Expect(status.ConfigHash).ToNot(BeEmpty())
+						_, object, _ = client.UpdateArgsForCall(0)
+						Expect(object.(*qjv1a1.QuarksJob).Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerDone))
+
+						// Requeued by the meltdown
+						object.(*qjv1a1.QuarksJob).DeepCopyInto(&qJob)
+						_, err = act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("Skipping run of quarks job").Len()).To(Equal(1))
+					})
+
+					It("doesn't start a workflow step, which is cancelled during its meltdown", func() {
+						jobs = nil
+						qJob.Labels = map[string]string{qjv1a1.LabelWorkflowName: "deploy", qjv1a1.LabelWorkflowStep: "migrate"}
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerOnce
+						now := metav1.Now()
+						qJob.Status.LastReconcile = &now
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						_, object, _ := client.UpdateArgsForCall(0)
+						object.(*qjv1a1.QuarksJob).DeepCopyInto(&qJob)
+
+						// Requeued at the end of the meltdown
+						over := metav1.NewTime(time.Now().Add(-config.MeltdownDuration - time.Second))
+						qJob.Status.LastReconcile = &over
+						_, err = act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(qJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerDone))
+
+						_, object, _ = statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						Expect(meta.IsStatusConditionTrue(object.(*qjv1a1.QuarksJob).Status.Conditions, qjv1a1.ConditionCancelled)).To(BeTrue())
+					})
+				})
+			})
+
+			Context("and the cluster supports projected service account tokens", func() {
+				BeforeEach(func() {
+					opConfig.ServiceAccountToken.Projected = true
+					qJob.Spec.Template.Spec.Template.Spec.ServiceAccountName = "persist-output"
+					serviceAccount.Secrets = nil
+				})
+
+				It("mounts a projected token instead of the token secret", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(job).ToNot(BeNil())
+
+					podSpec := job.Spec.Template.Spec
+					Expect(podSpec.ServiceAccountName).To(Equal("persist-output"))
+					Expect(podSpec.AutomountServiceAccountToken).To(BeNil())
+
+					var volume corev1.Volume
+					for _, v := range podSpec.Volumes {
+						if v.Name == "persist-output-token" {
+							volume = v
+						}
+					}
+					Expect(volume.Projected).ToNot(BeNil())
+					Expect(volume.Projected.Sources).To(HaveLen(3))
+					token := volume.Projected.Sources[0].ServiceAccountToken
+					Expect(token.Path).To(Equal("token"))
+					Expect(*token.ExpirationSeconds).To(Equal(int64(operatorconfig.DefaultServiceAccountTokenExpiration)))
+					Expect(volume.Projected.Sources[1].ConfigMap.Name).To(Equal("kube-root-ca.crt"))
+
+					container := podSpec.Containers[len(podSpec.Containers)-1]
+					Expect(container.Name).To(Equal("output-persist"))
+					Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+						Name:      "persist-output-token",
+						ReadOnly:  true,
+						MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+					}))
+				})
+			})
+
+			Context("and the output-persist container is configured", func() {
+				outputPersistContainer := func() corev1.Container {
+					containers := job.Spec.Template.Spec.Containers
+					return containers[len(containers)-1]
+				}
+
+				BeforeEach(func() {
+					err := opConfig.SetupSidecar(
+						`{"requests":{"cpu":"10m","memory":"32Mi"}}`,
+						`{"runAsNonRoot":true}`,
+					)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("uses the operator defaults", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					container := outputPersistContainer()
+					Expect(container.Name).To(Equal("output-persist"))
+					Expect(container.Resources.Requests.Cpu().String()).To(Equal("10m"))
+					Expect(container.Resources.Requests.Memory().String()).To(Equal("32Mi"))
+					Expect(*container.SecurityContext.RunAsNonRoot).To(BeTrue())
+				})
+
+				Context("and the quarks job overrides the defaults", func() {
+					BeforeEach(func() {
+						qJob.Spec.Output = &qjv1a1.Output{
+							Sidecar: &qjv1a1.Sidecar{
+								Image:           "registry.example.com/quarks-job:1.0",
+								ImagePullPolicy: corev1.PullAlways,
+								Resources: &corev1.ResourceRequirements{
+									Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+								},
+								SecurityContext: &corev1.SecurityContext{RunAsUser: pointers.Int64(1000)},
+								Env: []corev1.EnvVar{
+									{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+									{Name: "NAMESPACE", Value: "other"},
+								},
+							},
+						}
+					})
+
+					It("uses the quarks job's settings", func() {
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+
+						container := outputPersistContainer()
+						Expect(container.Image).To(Equal("registry.example.com/quarks-job:1.0"))
+						Expect(container.ImagePullPolicy).To(Equal(corev1.PullAlways))
+						Expect(container.Resources.Requests).To(BeEmpty())
+						Expect(container.Resources.Limits.Memory().String()).To(Equal("64Mi"))
+						Expect(container.SecurityContext.RunAsNonRoot).To(BeNil())
+						Expect(*container.SecurityContext.RunAsUser).To(Equal(int64(1000)))
+						Expect(container.Env).To(ConsistOf(
+							corev1.EnvVar{Name: "NAMESPACE", Value: qJob.Namespace},
+							corev1.EnvVar{Name: "HTTPS_PROXY", Value: "http://proxy:3128"},
+						))
+					})
+				})
 			})
 
 			Context("and the errand is an auto-errand", func() {
 				BeforeEach(func() {
 					qJob = env.AutoErrandQuarksJob("fake-qj")
-					qJobTime := metav1.NewTime(metav1.Now().Add(ReconcileSkipDuration))
+					qJobTime := metav1.NewTime(metav1.Now().Add(config.MeltdownDuration))
 					qJob.Status.LastReconcile = &qJobTime
-					serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
-					client = fakes.FakeClient{}
-					statusWriter = fakes.FakeStatusWriter{}
-					mgr.GetClientReturns(&client)
-					client.GetCalls(clientGetStub)
-					client.StatusCalls(func() crc.StatusWriter { return &statusWriter })
-
 					request = newRequest(qJob)
 				})
 
@@ -309,6 +907,57 @@ var _ = Describe("ErrandReconciler", func() {
 					Expect(result.Requeue).To(BeFalse())
 				})
 
+				Context("when the quarks job is suspended", func() {
+					BeforeEach(func() {
+						qJob.Spec.Suspend = true
+					})
+
+					It("queues the run instead of creating a job", func() {
+						result, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.Requeue).To(BeFalse())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(client.UpdateCallCount()).To(Equal(0))
+
+						_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+						Expect(object.(*qjv1a1.QuarksJob).Status.PendingRun).To(Equal(qjv1a1.TriggerReasonOnce))
+						Expect(logs.FilterMessageSnippet("Queued 'once' run of suspended quarks job").Len()).To(Equal(1))
+					})
+
+					It("keeps a single pending run", func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+						qJob.Status.PendingRun = qjv1a1.TriggerReasonConfigChange
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						// only the meltdown status update
+						Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+					})
+
+					It("still runs manual errands", func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerNow
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+					})
+				})
+
+				It("runs the pending run once resumed", func() {
+					qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+					qJob.Status.PendingRun = qjv1a1.TriggerReasonConfigChange
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.CreateCallCount()).To(Equal(1))
+					_, object, _ := client.CreateArgsForCall(0)
+					Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationTriggerReason, "config-change"))
+
+					_, object, _ = statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					Expect(object.(*qjv1a1.QuarksJob).Status.PendingRun).To(BeEmpty())
+				})
+
 				It("handles an error when updating job's strategy failed", func() {
 					callQueue := helper.NewCallQueue(
 						func(context context.Context, object crc.Object) error {
@@ -373,18 +1022,13 @@ var _ = Describe("ErrandReconciler", func() {
 					s1 := env.DefaultSecret("secret1", qJob.Namespace)
 					secret = &s1
 
-					serviceAccount = env.DefaultServiceAccount("persist-output-service-account", qJob.Namespace)
 					qJobName = "fake-qj"
 					qJob = env.AutoErrandQuarksJob(qJobName)
-					qJobTime := metav1.NewTime(metav1.Now().Add(ReconcileSkipDuration))
+					qJobTime := metav1.NewTime(metav1.Now().Add(config.MeltdownDuration))
 					qJob.Status.LastReconcile = &qJobTime
 					qJob.Spec.Template = env.ConfigJobTemplate()
 					qJob.Spec.UpdateOnConfigChange = true
 					qJob.Spec.Trigger.Strategy = qjv1a1.TriggerOnce
-					client = fakes.FakeClient{}
-					mgr.GetClientReturns(&client)
-					client.StatusCalls(func() crc.StatusWriter { return &fakes.FakeStatusWriter{} })
-
 					request = newRequest(qJob)
 				})
 
@@ -426,7 +1070,181 @@ var _ = Describe("ErrandReconciler", func() {
 					Expect(result.Requeue).To(BeFalse())
 				})
 
+				It("should pass the trigger reason to the job", func() {
+					qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+					client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+						switch obj := obj.(type) {
+						case *corev1.Namespace:
+							namespace.DeepCopyInto(obj)
+						case *qjv1a1.QuarksJob:
+							qJob.DeepCopyInto(obj)
+						case *corev1.ConfigMap:
+							configMap.DeepCopyInto(obj)
+						case *corev1.Secret:
+							secret.DeepCopyInto(obj)
+						case *corev1.ServiceAccount:
+							serviceAccount.DeepCopyInto(obj)
+						}
+						return nil
+					})
+					var job *batchv1.Job
+					client.CreateCalls(func(_ context.Context, object crc.Object, _ ...crc.CreateOption) error {
+						job = object.(*batchv1.Job)
+						return nil
+					})
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+						corev1.EnvVar{Name: "QUARKS_TRIGGER_REASON", Value: "config-change"},
+					))
+				})
+
+				Context("when the config hash is known", func() {
+					var (
+						job     *batchv1.Job
+						oldHash string
+						newHash string
+					)
+
+					BeforeEach(func() {
+						qJob.Spec.Trigger.Strategy = qjv1a1.TriggerDone
+						client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+							switch obj := obj.(type) {
+							case *corev1.Namespace:
+								namespace.DeepCopyInto(obj)
+							case *qjv1a1.QuarksJob:
+								qJob.DeepCopyInto(obj)
+							case *corev1.ConfigMap:
+								configMap.DeepCopyInto(obj)
+							case *corev1.Secret:
+								secret.DeepCopyInto(obj)
+							case *corev1.ServiceAccount:
+								serviceAccount.DeepCopyInto(obj)
+							}
+							return nil
+						})
+						client.CreateCalls(func(_ context.Context, object crc.Object, _ ...crc.CreateOption) error {
+							job = object.(*batchv1.Job)
+							return nil
+						})
+
+						var err error
+						oldHash, err = reference.ConfigHash(context.Background(), &client, vss.NewVersionedSecretStore(&client), &qJob)
+						Expect(err).ToNot(HaveOccurred())
+						configMap.Data = map[string]string{"changed": "value"}
+						newHash, err = reference.ConfigHash(context.Background(), &client, vss.NewVersionedSecretStore(&client), &qJob)
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("doesn't depend on metadata", func() {
+						configMap.Annotations = map[string]string{"applied": "again"}
+						hash, err := reference.ConfigHash(context.Background(), &client, vss.NewVersionedSecretStore(&client), &qJob)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(hash).To(Equal(newHash))
+						Expect(hash).ToNot(Equal(oldHash))
+					})
+
+					It("skips the run if the config didn't change since the last successful run", func() {
+						qJob.Status.SucceededConfigHash = newHash
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+						Expect(logs.FilterMessageSnippet("referenced configuration didn't change").Len()).To(Equal(1))
+					})
+
+					It("skips the run if the config didn't change since the start of the running job", func() {
+						qJob.Status.SucceededConfigHash = oldHash
+						qJob.Status.ConfigHash = newHash
+						qJob.Status.Conditions = []metav1.Condition{{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionUnknown, Reason: "Running"}}
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(0))
+					})
+
+					It("runs and records the hash if the config changed", func() {
+						qJob.Status.SucceededConfigHash = oldHash
+
+						_, err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+						Expect(job.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationConfigHash, newHash))
+					})
+
+					It("runs if a referenced versioned secret was rotated", func() {
+						versioned := func(version int, value string) corev1.Secret {
+							return corev1.Secret{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:      fmt.Sprintf("creds-v%d", version),
+									Namespace: qJob.Namespace,
+									Labels: map[string]string{
+										vss.LabelSecretKind: vss.VersionSecretKind,
+										vss.LabelVersion:    fmt.Sprint(version),
+									},
+								},
+								Data: map[string][]byte{"password": []byte(value)},
+							}
+						}
+						versions := []corev1.Secret{versioned(1, "old")}
+						podSpec := &qJob.Spec.Template.Spec.Template.Spec
+						podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+							Name:         "creds",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "creds-v1"}},
+						})
+						client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+							switch obj := obj.(type) {
+							case *corev1.Namespace:
+								namespace.DeepCopyInto(obj)
+							case *qjv1a1.QuarksJob:
+								qJob.DeepCopyInto(obj)
+							case *corev1.ConfigMap:
+								configMap.DeepCopyInto(obj)
+							case *corev1.Secret:
+								if nn.Name == secret.Name {
+									secret.DeepCopyInto(obj)
+									return nil
+								}
+								for _, s := range versions {
+									if s.Name == nn.Name {
+										s.DeepCopyInto(obj)
+										return nil
+									}
+								}
+								return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+							case *corev1.ServiceAccount:
+								serviceAccount.DeepCopyInto(obj)
+							}
+							return nil
+						})
+						client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+							if list, ok := object.(*corev1.SecretList); ok {
+								list.Items = versions
+							}
+							return nil
+						})
+						store := vss.NewVersionedSecretStore(&client)
+
+						hash, err := reference.ConfigHash(context.Background(), &client, store, &qJob)
+						Expect(err).ToNot(HaveOccurred())
+						qJob.Status.SucceededConfigHash = hash
+
+						versions = append(versions, versioned(2, "new"))
+						rotatedHash, err := reference.ConfigHash(context.Background(), &client, store, &qJob)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(rotatedHash).ToNot(Equal(hash))
+
+						_, err = act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(client.CreateCallCount()).To(Equal(1))
+						Expect(job.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationConfigHash, rotatedHash))
+					})
+				})
+
 				It("should skip when references are missing", func() {
+					statusWriter := &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
 					client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
 						switch obj := obj.(type) {
 						case *corev1.Namespace:
@@ -445,7 +1263,15 @@ var _ = Describe("ErrandReconciler", func() {
 					result, err := act()
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result.Requeue).To(BeTrue())
-					Expect(logs.FilterMessageSnippet(fmt.Sprintf("Skip create job '/%s' due to configMap 'config1' not found", qJobName)).Len()).To(Equal(1))
+					Expect(result.RequeueAfter).To(Equal(MissingReferencesMinBackoff))
+					Expect(logs.FilterMessageSnippet(fmt.Sprintf("Skip create job '/%s' due to missing reference 'configmap/config1'", qJobName)).Len()).To(Equal(1))
+
+					_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					status := object.(*qjv1a1.QuarksJob).Status
+					Expect(status.MissingReferences).To(Equal([]string{"configmap/config1", "secret/secret1"}))
+					Expect(status.MissingReferencesSince).ToNot(BeNil())
+					qJob.Status.MissingReferences = status.MissingReferences
+					qJob.Status.MissingReferencesSince = status.MissingReferencesSince
 
 					client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
 						switch obj := obj.(type) {
@@ -468,7 +1294,42 @@ var _ = Describe("ErrandReconciler", func() {
 					result, err = act()
 					Expect(err).ToNot(HaveOccurred())
 					Expect(result.Requeue).To(BeTrue())
-					Expect(logs.FilterMessageSnippet(fmt.Sprintf("Skip create job '/%s' due to secret 'secret1' not found", qJobName)).Len()).To(Equal(1))
+					Expect(logs.FilterMessageSnippet(fmt.Sprintf("Skip create job '/%s' due to missing reference 'secret/secret1'", qJobName)).Len()).To(Equal(2))
+
+					_, object, _ = statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					Expect(object.(*qjv1a1.QuarksJob).Status.MissingReferences).To(Equal([]string{"secret/secret1"}))
+				})
+
+				It("should clear the missing references after creating the job", func() {
+					since := metav1.Now()
+					qJob.Status.MissingReferences = []string{"secret/secret1"}
+					qJob.Status.MissingReferencesSince = &since
+					statusWriter := &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+					client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+						switch obj := obj.(type) {
+						case *corev1.Namespace:
+							namespace.DeepCopyInto(obj)
+						case *qjv1a1.QuarksJob:
+							qJob.DeepCopyInto(obj)
+						case *corev1.ConfigMap:
+							configMap.DeepCopyInto(obj)
+						case *corev1.Secret:
+							secret.DeepCopyInto(obj)
+						case *corev1.ServiceAccount:
+							serviceAccount.DeepCopyInto(obj)
+						}
+						return nil
+					})
+
+					result, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.Requeue).To(BeFalse())
+
+					_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					status := object.(*qjv1a1.QuarksJob).Status
+					Expect(status.MissingReferences).To(BeEmpty())
+					Expect(status.MissingReferencesSince).To(BeNil())
 				})
 			})
 		})
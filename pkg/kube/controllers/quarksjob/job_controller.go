@@ -13,13 +13,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 )
 
 // AddJob creates a new Job controller to collect the output from jobs, persist
 // that output as a secret and delete the k8s job afterwards.
-func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+func AddJob(ctx context.Context, config *config.Config, _ *operatorconfig.Config, mgr manager.Manager) error {
 	ctx = ctxlog.NewContextWithRecorder(ctx, "job-reconciler", mgr.GetEventRecorderFor("job-recorder"))
 	jobReconciler, err := NewJobReconciler(ctx, config, mgr)
 	if err != nil {
@@ -35,14 +36,22 @@ func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) err
 
 	nsPredicate := newNSPredicate(ctx, mgr.GetClient(), config.MonitoredID)
 
-	predicate := predicate.Funcs{
+	return jobController.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForObject{}, nsPredicate, newJobPredicate(ctx))
+}
+
+// newJobPredicate passes the jobs of quarks jobs, which changed to a final
+// state, i.e. they succeeded or failed. Finished jobs, which are kept for the
+// history, don't pass on later updates.
+func newJobPredicate(ctx context.Context) predicate.Funcs {
+	return predicate.Funcs{
 		// We're only interested in Jobs going from Active to final state (Succeeded or Failed)
 		CreateFunc:  func(e event.CreateEvent) bool { return false },
 		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
 		GenericFunc: func(e event.GenericEvent) bool { return false },
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			o := e.ObjectNew.(*batchv1.Job)
-			if !o.GetDeletionTimestamp().IsZero() {
+			o := e.ObjectOld.(*batchv1.Job)
+			n := e.ObjectNew.(*batchv1.Job)
+			if !n.GetDeletionTimestamp().IsZero() {
 				return false
 			}
 
@@ -50,9 +59,10 @@ func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) err
 				return false
 			}
 
-			shouldProcessEvent := o.Status.Succeeded == 1 || o.Status.Failed > *o.Spec.BackoffLimit
+			// Failed includes jobs, which exceeded their activeDeadlineSeconds
+			shouldProcessEvent := !jobDone(o) && jobDone(n)
 			if shouldProcessEvent {
-				ctxlog.NewPredicateEvent(o).Debug(
+				ctxlog.NewPredicateEvent(n).Debug(
 					ctx, e.ObjectNew, "batchv1.Job",
 					fmt.Sprintf("Update predicate passed for '%s/%s', existing batchv1.Job has changed to a defined final state",
 						e.ObjectNew.GetNamespace(),
@@ -63,7 +73,6 @@ func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) err
 			return shouldProcessEvent
 		},
 	}
-	return jobController.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForObject{}, nsPredicate, predicate)
 }
 
 // isEJobJob matches our jobs
@@ -0,0 +1,89 @@
+package quarksjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	qjv1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+)
+
+var _ = Describe("jobPredicate", func() {
+	var (
+		jobPredicate predicate.Funcs
+		old, job     *batchv1.Job
+	)
+
+	BeforeEach(func() {
+		jobPredicate = newJobPredicate(context.TODO())
+		old = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo-job",
+				Namespace: "default",
+				Labels:    map[string]string{qjv1.LabelQJobName: "foo"},
+			},
+			Spec: batchv1.JobSpec{BackoffLimit: pointers.Int32(2)},
+		}
+		job = old.DeepCopy()
+	})
+
+	act := func() bool {
+		return jobPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: job})
+	}
+
+	It("passes succeeded jobs", func() {
+		job.Status.Succeeded = 1
+		Expect(act()).To(BeTrue())
+	})
+
+	It("passes jobs, which exceeded their backoff limit", func() {
+		job.Status.Failed = 3
+		Expect(act()).To(BeTrue())
+	})
+
+	It("passes jobs, which failed by exceeding their deadline", func() {
+		job.Status.Failed = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded"},
+		}
+		Expect(act()).To(BeTrue())
+	})
+
+	It("ignores finished jobs, which are updated again", func() {
+		old.Status.Succeeded = 1
+		job.Status.Succeeded = 1
+		Expect(act()).To(BeFalse())
+
+		old.Status.Succeeded = 0
+		old.Status.Failed = 3
+		job.Status.Succeeded = 0
+		job.Status.Failed = 3
+		Expect(act()).To(BeFalse())
+	})
+
+	It("ignores running jobs", func() {
+		job.Status.Active = 1
+		job.Status.Failed = 1
+		Expect(act()).To(BeFalse())
+	})
+
+	It("ignores jobs without backoff limit", func() {
+		job.Spec.BackoffLimit = nil
+		job.Status.Failed = 1
+		Expect(act()).To(BeFalse())
+	})
+
+	It("ignores jobs of other controllers", func() {
+		job.Labels = nil
+		job.Status.Succeeded = 1
+		Expect(act()).To(BeFalse())
+	})
+})
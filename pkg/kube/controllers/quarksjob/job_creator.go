@@ -3,7 +3,11 @@ package quarksjob
 import (
 	"context"
 	"fmt"
+	"math"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
@@ -11,13 +15,15 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 	"code.cloudfoundry.org/quarks-utils/pkg/names"
-	"code.cloudfoundry.org/quarks-utils/pkg/podref"
 	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
 )
 
@@ -26,24 +32,42 @@ const (
 	// EnvNamespace is the namespace in which the jobs run, used by
 	// persist-output to create the secrets
 	EnvNamespace = "NAMESPACE"
+
+	// EnvJobName is set on the job's containers to the name of the QuarksJob
+	EnvJobName = "QUARKS_JOB_NAME"
+	// EnvRunID is set on the job's containers to the unique ID of the run
+	EnvRunID = "QUARKS_RUN_ID"
+	// EnvTriggerReason is set on the job's containers to the reason the run
+	// was started, see qjv1a1.TriggerReason
+	EnvTriggerReason = "QUARKS_TRIGGER_REASON"
+	// EnvAttempt is set on the job's containers to the number of the
+	// attempt, which is greater than 1 for retries
+	EnvAttempt = "QUARKS_ATTEMPT"
+	// EnvOutputDir is set on the job's containers to the directory, which
+	// is read by the output-persist container
+	EnvOutputDir = "QUARKS_OUTPUT_DIR"
+	// EnvParameterPrefix is prepended to the names of the parameters of
+	// runs triggered by the HTTP trigger endpoint
+	EnvParameterPrefix = "QUARKS_PARAM_"
 )
 
 type setOwnerReferenceFunc func(owner, object metav1.Object, scheme *runtime.Scheme) error
 
 // NewJobCreator returns a new job creator
-func NewJobCreator(client crc.Client, scheme *runtime.Scheme, f setOwnerReferenceFunc, config *config.Config, store vss.VersionedSecretStore) JobCreator {
+func NewJobCreator(client crc.Client, scheme *runtime.Scheme, f setOwnerReferenceFunc, config *config.Config, opConfig *operatorconfig.Config, store vss.VersionedSecretStore) JobCreator {
 	return jobCreatorImpl{
 		client:            client,
 		scheme:            scheme,
 		setOwnerReference: f,
 		config:            config,
+		opConfig:          opConfig,
 		store:             store,
 	}
 }
 
 // JobCreator is the interface that wraps the basic Create method.
 type JobCreator interface {
-	Create(ctx context.Context, qJob qjv1a1.QuarksJob) (retry bool, err error)
+	Create(ctx context.Context, qJob qjv1a1.QuarksJob, reason qjv1a1.TriggerReason, attempt int32) (missingReferences []string, err error)
 }
 
 type jobCreatorImpl struct {
@@ -51,42 +75,64 @@ type jobCreatorImpl struct {
 	scheme            *runtime.Scheme
 	setOwnerReference setOwnerReferenceFunc
 	config            *config.Config
+	opConfig          *operatorconfig.Config
 	store             vss.VersionedSecretStore
 }
 
 // Create satisfies the JobCreator interface. It creates a Job to complete ExJob. It returns the
-// retry if one of the references are not present.
-func (j jobCreatorImpl) Create(ctx context.Context, qJob qjv1a1.QuarksJob) (bool, error) {
+// references, which are not present, instead of creating the job. The attempt
+// counts the runs of the QuarksJob's retry policy, starting at 1.
+func (j jobCreatorImpl) Create(ctx context.Context, qJob qjv1a1.QuarksJob, reason qjv1a1.TriggerReason, attempt int32) ([]string, error) {
 	namespace := qJob.Namespace
 	template := qJob.Spec.Template.DeepCopy()
+	runID := string(uuid.NewUUID())
+
+	defaults, err := j.getDefaults(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defaults {
+		if err := mergeDefaults(d, &template.Spec.Template); err != nil {
+			return nil, errors.Wrapf(err, "could not apply defaults to qJob '%s'", qJob.GetNamespacedName())
+		}
+	}
+
+	// Validate the references of the template merged with the defaults
+	missing, err := j.missingReferences(ctx, qJob, template.Spec.Template.Spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return missing, nil
+	}
 
 	serviceAccount, err := j.getServiceAccountName(ctx, namespace)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	serviceAccountVolume, serviceAccountVolumeMount, err := j.serviceAccountMount(ctx, namespace, serviceAccount)
+	serviceAccountVolume, serviceAccountVolumeMount, err := j.serviceAccountMount(ctx, &qJob, serviceAccount, &template.Spec.Template.Spec)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// Set serviceaccount to the container
 	template.Spec.Template.Spec.Volumes = append(template.Spec.Template.Spec.Volumes, *serviceAccountVolume)
 
-	ctxlog.Debugf(ctx, "Add persist output container, using DOCKER_IMAGE_TAG=%s", config.GetOperatorDockerImage())
 	// Create a container for persisting output
-	outputPersistContainer := corev1.Container{
-		Name:            "output-persist",
-		Image:           config.GetOperatorDockerImage(),
-		ImagePullPolicy: config.GetOperatorImagePullPolicy(),
-		Args:            []string{"persist-output"},
-		Env: []corev1.EnvVar{
-			{
-				Name:  EnvNamespace,
-				Value: namespace,
-			},
-		},
-		VolumeMounts: []corev1.VolumeMount{*serviceAccountVolumeMount},
+	outputPersistContainer := newOutputPersistContainer(namespace, qJob.Spec.Output, j.opConfig.Sidecar)
+	outputPersistContainer.VolumeMounts = []corev1.VolumeMount{*serviceAccountVolumeMount}
+	ctxlog.Debugf(ctx, "Add persist output container, using image '%s'", outputPersistContainer.Image)
+
+	// Run metadata for the containers and init containers of the template
+	runEnv := append([]corev1.EnvVar{
+		{Name: EnvJobName, Value: qJob.Name},
+		{Name: EnvRunID, Value: runID},
+		{Name: EnvTriggerReason, Value: string(reason)},
+		{Name: EnvAttempt, Value: strconv.Itoa(int(attempt))},
+	}, parameterEnv(qJob.Status.Parameters)...)
+	for i := range template.Spec.Template.Spec.InitContainers {
+		template.Spec.Template.Spec.InitContainers[i].Env = setEnv(template.Spec.Template.Spec.InitContainers[i].Env, runEnv...)
 	}
 
 	// Loop through containers and add quarks logging volume specs.
@@ -94,8 +140,10 @@ func (j jobCreatorImpl) Create(ctx context.Context, qJob qjv1a1.QuarksJob) (bool
 
 		// Add pod volume specs to the pod
 		podVolumeSpec := corev1.Volume{
-			Name:         names.Sanitize(fmt.Sprintf("%s%s", "output-", container.Name)),
-			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			Name: names.Sanitize(fmt.Sprintf("%s%s", "output-", container.Name)),
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{
+				SizeLimit: j.opConfig.Sidecar.VolumeSizeLimit(),
+			}},
 		}
 		template.Spec.Template.Spec.Volumes = append(template.Spec.Template.Spec.Volumes, podVolumeSpec)
 
@@ -106,6 +154,12 @@ func (j jobCreatorImpl) Create(ctx context.Context, qJob qjv1a1.QuarksJob) (bool
 		}
 		template.Spec.Template.Spec.Containers[containerIndex].VolumeMounts = append(template.Spec.Template.Spec.Containers[containerIndex].VolumeMounts, containerVolumeMountSpec)
 
+		// Add run metadata
+		template.Spec.Template.Spec.Containers[containerIndex].Env = setEnv(template.Spec.Template.Spec.Containers[containerIndex].Env, runEnv...)
+		template.Spec.Template.Spec.Containers[containerIndex].Env = setEnv(template.Spec.Template.Spec.Containers[containerIndex].Env,
+			corev1.EnvVar{Name: EnvOutputDir, Value: filepath.Clean(mountPath)},
+		)
+
 		// Add container volume spec to output persist container
 		containerVolumeMountSpec.MountPath = filepath.Join(mountPath, container.Name)
 		outputPersistContainer.VolumeMounts = append(outputPersistContainer.VolumeMounts, containerVolumeMountSpec)
@@ -113,78 +167,195 @@ func (j jobCreatorImpl) Create(ctx context.Context, qJob qjv1a1.QuarksJob) (bool
 
 	// Add output persist container to the pod template
 	template.Spec.Template.Spec.Containers = append(template.Spec.Template.Spec.Containers, outputPersistContainer)
+	defaultSeccompProfile(&template.Spec.Template.Spec)
 
 	if template.Spec.Template.Labels == nil {
 		template.Spec.Template.Labels = map[string]string{}
 	}
 	template.Spec.Template.Labels[qjv1a1.LabelQJobName] = qJob.Name
+	runAnnotations := map[string]string{
+		qjv1a1.AnnotationRunID:         runID,
+		qjv1a1.AnnotationTriggerReason: string(reason),
+		qjv1a1.AnnotationAttempt:       strconv.Itoa(int(attempt)),
+	}
+	if qJob.Status.ConfigHash != "" {
+		runAnnotations[qjv1a1.AnnotationConfigHash] = qJob.Status.ConfigHash
+	}
+	// The run annotations have to match the injected env vars
+	if template.Spec.Template.Annotations == nil {
+		template.Spec.Template.Annotations = map[string]string{}
+	}
+	for k, v := range runAnnotations {
+		template.Spec.Template.Annotations[k] = v
+	}
 
-	if err := j.store.SetSecretReferences(ctx, qJob.Namespace, &template.Spec.Template.Spec); err != nil {
-		return false, err
+	if qJob.Spec.RunTimeout != nil {
+		template.Spec.ActiveDeadlineSeconds = runTimeoutSeconds(qJob.Spec.RunTimeout.Duration)
 	}
 
-	// Validate quarks job configmap and secrets references
-	err = j.validateReferences(ctx, qJob)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			// Requeue the job without error.
-			return true, nil
-		}
-		return false, err
+	if err := j.store.SetSecretReferences(ctx, qJob.Namespace, &template.Spec.Template.Spec); err != nil {
+		return nil, err
 	}
 
 	// Create k8s job
 	name, err := names.JobName(qJob.Name)
 	if err != nil {
-		return false, errors.Wrapf(err, "could not generate job name for qJob '%s'", qJob.GetNamespacedName())
+		return nil, errors.Wrapf(err, "could not generate job name for qJob '%s'", qJob.GetNamespacedName())
 	}
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: qJob.Namespace,
-			Labels:    map[string]string{qjv1a1.LabelQJobName: qJob.Name},
+			Name:        name,
+			Namespace:   qJob.Namespace,
+			Labels:      map[string]string{qjv1a1.LabelQJobName: qJob.Name},
+			Annotations: runAnnotations,
 		},
 		Spec: template.Spec,
 	}
 
 	if err := j.setOwnerReference(&qJob, job, j.scheme); err != nil {
-		return false, ctxlog.WithEvent(&qJob, "SetOwnerReferenceError").Errorf(ctx, "failed to set owner reference on job for '%s': %s", qJob.GetNamespacedName(), err)
+		return nil, ctxlog.WithEvent(&qJob, "SetOwnerReferenceError").Errorf(ctx, "failed to set owner reference on job for '%s': %s", qJob.GetNamespacedName(), err)
 	}
 
 	if err := j.client.Create(ctx, job); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			ctxlog.WithEvent(&qJob, "AlreadyRunning").Infof(ctx, "Skip '%s': already running", qJob.GetNamespacedName())
 			// Don't requeue the job.
-			return false, nil
+			return nil, nil
 		}
-		return true, err
+		return nil, err
 	}
 
-	return false, nil
+	return nil, nil
+}
+
+// runTimeoutSeconds rounds the run timeout up to full seconds, for the job's
+// activeDeadlineSeconds
+func runTimeoutSeconds(timeout time.Duration) *int64 {
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &seconds
 }
 
-func (j jobCreatorImpl) validateReferences(ctx context.Context, qJob qjv1a1.QuarksJob) error {
-	configMaps := podref.GetConfMapRefFromPod(qJob.Spec.Template.Spec.Template.Spec)
-	configMap := &corev1.ConfigMap{}
-	for configMapName := range configMaps {
-		if err := j.client.Get(ctx, crc.ObjectKey{Name: configMapName, Namespace: qJob.Namespace}, configMap); err != nil {
-			if apierrors.IsNotFound(err) {
-				ctxlog.Debugf(ctx, "Skip create job '%s' due to configMap '%s' not found", qJob.GetNamespacedName(), configMapName)
+// missingReferences returns the objects and keys referenced by the pod spec
+// of the quarks job, which don't exist. Optional references are skipped.
+func (j jobCreatorImpl) missingReferences(ctx context.Context, qJob qjv1a1.QuarksJob, spec corev1.PodSpec) ([]string, error) {
+	// keys of the referenced objects, nil if the object doesn't exist
+	objects := map[string]map[string]bool{}
+	missing := map[string]bool{}
+
+	for _, ref := range reference.GetPodReferences(spec) {
+		if ref.Optional {
+			continue
+		}
+
+		objectRef := qjv1a1.Reference(ref.Kind, ref.Name)
+		keys, ok := objects[objectRef]
+		if !ok {
+			var err error
+			keys, err = j.getReferencedKeys(ctx, qJob.Namespace, ref.Kind, ref.Name)
+			if err != nil {
+				return nil, err
 			}
-			return err
+			objects[objectRef] = keys
 		}
+
+		if keys == nil {
+			if !missing[objectRef] {
+				ctxlog.Debugf(ctx, "Skip create job '%s' due to missing reference '%s'", qJob.GetNamespacedName(), objectRef)
+			}
+			missing[objectRef] = true
+			continue
+		}
+
+		if ref.Key != "" && !keys[ref.Key] {
+			ctxlog.Debugf(ctx, "Skip create job '%s' due to missing reference '%s'", qJob.GetNamespacedName(), ref)
+			missing[ref.String()] = true
+		}
+	}
+
+	return sortedKeys(missing), nil
+}
+
+// getReferencedKeys returns the keys of a config map or secret, an empty
+// map for other kinds and nil if the object doesn't exist
+func (j jobCreatorImpl) getReferencedKeys(ctx context.Context, namespace string, kind qjv1a1.ReferenceKind, name string) (map[string]bool, error) {
+	var object crc.Object
+	switch kind {
+	case qjv1a1.ReferenceConfigMap:
+		object = &corev1.ConfigMap{}
+	case qjv1a1.ReferenceSecret:
+		object = &corev1.Secret{}
+	case qjv1a1.ReferencePersistentVolumeClaim:
+		object = &corev1.PersistentVolumeClaim{}
+	case qjv1a1.ReferenceServiceAccount:
+		object = &corev1.ServiceAccount{}
+	default:
+		return nil, errors.Errorf("unknown reference kind '%s'", kind)
 	}
 
-	secrets := podref.GetSecretRefFromPodSpec(qJob.Spec.Template.Spec.Template.Spec)
-	secret := &corev1.Secret{}
-	for secretName := range secrets {
-		if err := j.client.Get(ctx, crc.ObjectKey{Name: secretName, Namespace: qJob.Namespace}, secret); err != nil {
-			if apierrors.IsNotFound(err) {
-				ctxlog.Debugf(ctx, "Skip create job '%s' due to secret '%s' not found", qJob.GetNamespacedName(), secretName)
+	if err := j.client.Get(ctx, crc.ObjectKey{Name: name, Namespace: namespace}, object); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get %s '%s/%s'", kind, namespace, name)
+	}
+
+	keys := map[string]bool{}
+	switch object := object.(type) {
+	case *corev1.ConfigMap:
+		for k := range object.Data {
+			keys[k] = true
+		}
+		for k := range object.BinaryData {
+			keys[k] = true
+		}
+	case *corev1.Secret:
+		for k := range object.Data {
+			keys[k] = true
+		}
+		for k := range object.StringData {
+			keys[k] = true
+		}
+	}
+	return keys, nil
+}
+
+// parameterEnv returns the env vars for the run's parameters, sorted by name
+func parameterEnv(parameters map[string]string) []corev1.EnvVar {
+	env := make([]corev1.EnvVar, 0, len(parameters))
+	for name, value := range parameters {
+		env = append(env, corev1.EnvVar{Name: EnvParameterPrefix + name, Value: value})
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	return env
+}
+
+// setEnv sets the env vars, replacing existing ones with the same name
+func setEnv(env []corev1.EnvVar, vars ...corev1.EnvVar) []corev1.EnvVar {
+	for _, v := range vars {
+		found := false
+		for i := range env {
+			if env[i].Name == v.Name {
+				env[i] = v
+				found = true
+				break
 			}
-			return err
 		}
+		if !found {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return nil
+	sort.Strings(keys)
+	return keys
 }
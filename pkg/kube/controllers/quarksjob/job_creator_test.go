@@ -0,0 +1,464 @@
+package quarksjob_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers/fakes"
+	. "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/quarksjob"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-job/testing"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("JobCreator", func() {
+	var (
+		env            testing.Catalog
+		client         *fakes.FakeClient
+		qJob           qjv1a1.QuarksJob
+		serviceAccount corev1.ServiceAccount
+		job            *batchv1.Job
+		recorder       *record.FakeRecorder
+		opConfig       *operatorconfig.Config
+	)
+
+	namespace := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{qjv1a1.LabelServiceAccount: "persist-output"}}}
+
+	setOwnerReference := func(_, _ metav1.Object, _ *runtime.Scheme) error { return nil }
+
+	create := func() {
+		_, log := helper.NewTestLogger()
+		ctx := ctxlog.NewContextWithRecorder(ctxlog.NewParentContext(log), "test", recorder)
+		config := helper.NewConfigWithTimeout(0)
+		creator := NewJobCreator(client, scheme.Scheme, setOwnerReference, config, opConfig, vss.NewVersionedSecretStore(client))
+
+		missing, err := creator.Create(ctx, qJob, qjv1a1.TriggerReasonManual, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(BeEmpty())
+		Expect(job).ToNot(BeNil())
+	}
+
+	BeforeEach(func() {
+		job = nil
+		opConfig = operatorconfig.NewDefaultConfig()
+		recorder = record.NewFakeRecorder(10)
+		serviceAccount = env.DefaultServiceAccount("persist-output", "default")
+
+		client = &fakes.FakeClient{}
+		client.GetCalls(func(_ context.Context, nn types.NamespacedName, obj crc.Object) error {
+			switch obj := obj.(type) {
+			case *corev1.Namespace:
+				namespace.DeepCopyInto(obj)
+				return nil
+			case *corev1.ServiceAccount:
+				serviceAccount.DeepCopyInto(obj)
+				return nil
+			}
+			return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+		})
+		client.CreateCalls(func(_ context.Context, object crc.Object, _ ...crc.CreateOption) error {
+			if j, ok := object.(*batchv1.Job); ok {
+				job = j
+			}
+			return nil
+		})
+	})
+
+	Describe("run metadata", func() {
+		BeforeEach(func() {
+			qJob = env.ErrandQuarksJob("fake-qj", "default")
+			qJob.Spec.Template.Spec.Template.Spec.Containers[0].Env = append(
+				qJob.Spec.Template.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: "user-defined"},
+			)
+		})
+
+		It("injects the run metadata into the template's containers", func() {
+			create()
+			runID := job.Annotations[qjv1a1.AnnotationRunID]
+			Expect(runID).ToNot(BeEmpty())
+			Expect(job.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationTriggerReason, "manual"))
+			Expect(job.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationAttempt, "1"))
+			Expect(job.Spec.Template.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationRunID, runID))
+
+			containers := job.Spec.Template.Spec.Containers
+			Expect(containers[0].Env).To(ContainElements(
+				corev1.EnvVar{Name: "QUARKS_JOB_NAME", Value: "fake-qj"},
+				corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: runID},
+				corev1.EnvVar{Name: "QUARKS_TRIGGER_REASON", Value: "manual"},
+				corev1.EnvVar{Name: "QUARKS_ATTEMPT", Value: "1"},
+				corev1.EnvVar{Name: "QUARKS_OUTPUT_DIR", Value: "/mnt/quarks"},
+			))
+			Expect(containers[0].Env).ToNot(ContainElement(corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: "user-defined"}))
+			Expect(containers[1].Name).To(Equal("output-persist"))
+			Expect(containers[1].Env).ToNot(ContainElement(corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: runID}))
+		})
+
+		It("injects the run metadata into the template's init containers", func() {
+			qJob.Spec.Template.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox"}}
+			qJob.Status.Parameters = map[string]string{"VERSION": "1.2.3"}
+			create()
+
+			runID := job.Annotations[qjv1a1.AnnotationRunID]
+			env := job.Spec.Template.Spec.InitContainers[0].Env
+			Expect(env).To(ContainElements(
+				corev1.EnvVar{Name: "QUARKS_JOB_NAME", Value: "fake-qj"},
+				corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: runID},
+				corev1.EnvVar{Name: "QUARKS_PARAM_VERSION", Value: "1.2.3"},
+			))
+			for _, e := range env {
+				Expect(e.Name).ToNot(Equal("QUARKS_OUTPUT_DIR"))
+			}
+		})
+
+		It("overwrites run annotations of the template", func() {
+			qJob.Spec.Template.Spec.Template.Annotations = map[string]string{
+				qjv1a1.AnnotationRunID:   "user-defined",
+				qjv1a1.AnnotationAttempt: "7",
+			}
+			create()
+
+			runID := job.Annotations[qjv1a1.AnnotationRunID]
+			Expect(job.Spec.Template.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationRunID, runID))
+			Expect(job.Spec.Template.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationAttempt, "1"))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "QUARKS_RUN_ID", Value: runID}))
+		})
+
+		It("passes the parameters of the run as env vars", func() {
+			qJob.Status.Parameters = map[string]string{"VERSION": "1.2.3", "ENV": "staging"}
+			create()
+
+			env := job.Spec.Template.Spec.Containers[0].Env
+			Expect(env).To(ContainElements(
+				corev1.EnvVar{Name: "QUARKS_PARAM_ENV", Value: "staging"},
+				corev1.EnvVar{Name: "QUARKS_PARAM_VERSION", Value: "1.2.3"},
+			))
+		})
+
+		It("uses a new run ID for every job", func() {
+			create()
+			first := job.Annotations[qjv1a1.AnnotationRunID]
+			create()
+			Expect(job.Annotations[qjv1a1.AnnotationRunID]).ToNot(Equal(first))
+		})
+	})
+
+	Describe("reference validation", func() {
+		var configMap corev1.ConfigMap
+
+		BeforeEach(func() {
+			configMap = env.DefaultConfigMap("config", "default")
+			configMap.Data = map[string]string{"present": "value"}
+			client.GetCalls(func(_ context.Context, nn types.NamespacedName, obj crc.Object) error {
+				switch obj := obj.(type) {
+				case *corev1.Namespace:
+					namespace.DeepCopyInto(obj)
+					return nil
+				case *corev1.ServiceAccount:
+					if nn.Name == "persist-output" {
+						serviceAccount.DeepCopyInto(obj)
+						return nil
+					}
+				case *corev1.ConfigMap:
+					if nn.Name == "config" {
+						configMap.DeepCopyInto(obj)
+						return nil
+					}
+				}
+				return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+			})
+
+			qJob = env.ErrandQuarksJob("fake-qj", "default")
+			podSpec := &qJob.Spec.Template.Spec.Template.Spec
+			podSpec.ServiceAccountName = "errand"
+			podSpec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+			podSpec.Volumes = []corev1.Volume{
+				{
+					Name: "projected",
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected-secret"}}},
+							{ConfigMap: &corev1.ConfigMapProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+								Items:                []corev1.KeyToPath{{Key: "present", Path: "a"}, {Key: "projected-key", Path: "b"}},
+							}},
+						},
+					}},
+				},
+				{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "data",
+					}},
+				},
+			}
+			podSpec.Containers[0].EnvFrom = []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env-secret"}}},
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "optional-config"}, Optional: pointers.Bool(true)}},
+			}
+			podSpec.Containers[0].Env = append(podSpec.Containers[0].Env,
+				corev1.EnvVar{Name: "KEY", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+					Key:                  "env-key",
+				}}},
+				corev1.EnvVar{Name: "OPTIONAL_KEY", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+					Key:                  "optional-key",
+					Optional:             pointers.Bool(true),
+				}}},
+			)
+		})
+
+		It("returns all missing objects and keys, except optional ones", func() {
+			_, log := helper.NewTestLogger()
+			creator := NewJobCreator(client, scheme.Scheme, setOwnerReference, helper.NewConfigWithTimeout(0), opConfig, vss.NewVersionedSecretStore(client))
+
+			missing, err := creator.Create(ctxlog.NewParentContext(log), qJob, qjv1a1.TriggerReasonManual, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([]string{
+				"configmap/config:env-key",
+				"configmap/config:projected-key",
+				"persistentvolumeclaim/data",
+				"secret/env-secret",
+				"secret/projected-secret",
+				"secret/registry",
+				"serviceaccount/errand",
+			}))
+			Expect(job).To(BeNil())
+		})
+	})
+
+	Describe("quarks job defaults", func() {
+		BeforeEach(func() {
+			qJob = env.ErrandQuarksJob("fake-qj", "default")
+			podSpec := &qJob.Spec.Template.Spec.Template.Spec
+			podSpec.NodeSelector = map[string]string{"pool": "errands"}
+			podSpec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+
+			get := client.GetStub
+			client.GetCalls(func(ctx context.Context, nn types.NamespacedName, obj crc.Object) error {
+				if _, ok := obj.(*corev1.Secret); ok && nn.Name == "registry" {
+					return nil
+				}
+				return get(ctx, nn, obj)
+			})
+			client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+				switch list := object.(type) {
+				case *qjv1a1.QuarksJobDefaultsList:
+					list.Items = []qjv1a1.QuarksJobDefaults{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+							Spec: qjv1a1.QuarksJobDefaultsSpec{
+								Labels:       map[string]string{"team": "platform"},
+								NodeSelector: map[string]string{"pool": "default", "zone": "z1"},
+								SecretLabels: map[string]string{"owner": "platform"},
+							},
+						},
+					}
+				case *qjv1a1.ClusterQuarksJobDefaultsList:
+					list.Items = []qjv1a1.ClusterQuarksJobDefaults{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+							Spec: qjv1a1.QuarksJobDefaultsSpec{
+								Labels:           map[string]string{"team": "cluster", "env": "prod"},
+								Tolerations:      []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}, {Key: "spot", Operator: corev1.TolerationOpExists}},
+								ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+								Resources: &corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+								},
+								SecretLabels: map[string]string{"owner": "cluster"},
+							},
+						},
+					}
+				}
+				return nil
+			})
+		})
+
+		It("merges the defaults into the pod template", func() {
+			create()
+			template := job.Spec.Template
+			Expect(template.Labels).To(HaveKeyWithValue("team", "platform"))
+			Expect(template.Labels).To(HaveKeyWithValue("env", "prod"))
+			Expect(template.Labels).To(HaveKeyWithValue(qjv1a1.LabelQJobName, "fake-qj"))
+			Expect(template.Spec.NodeSelector).To(Equal(map[string]string{"pool": "errands", "zone": "z1"}))
+			Expect(template.Spec.Tolerations).To(HaveLen(2))
+			Expect(template.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry"}))
+			Expect(template.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationDefaultSecretLabels, `{"owner":"platform"}`))
+		})
+
+		It("sets the default resources only for the template's containers", func() {
+			create()
+			containers := job.Spec.Template.Spec.Containers
+			Expect(containers[0].Resources.Requests.Cpu().String()).To(Equal("100m"))
+			Expect(containers[1].Name).To(Equal("output-persist"))
+			Expect(containers[1].Resources.Requests).To(BeEmpty())
+		})
+	})
+
+	Describe("persist-output service account", func() {
+		outputPersistMount := func() corev1.VolumeMount {
+			containers := job.Spec.Template.Spec.Containers
+			return containers[len(containers)-1].VolumeMounts[0]
+		}
+
+		volume := func(name string) corev1.Volume {
+			for _, v := range job.Spec.Template.Spec.Volumes {
+				if v.Name == name {
+					return v
+				}
+			}
+			return corev1.Volume{}
+		}
+
+		BeforeEach(func() {
+			opConfig.ServiceAccountToken.Projected = true
+			qJob = env.ErrandQuarksJob("fake-qj", "default")
+			serviceAccount.Secrets = nil
+		})
+
+		Context("when the template has no service account", func() {
+			It("keeps the template's identity and fails without a token secret", func() {
+				_, log := helper.NewTestLogger()
+				creator := NewJobCreator(client, scheme.Scheme, setOwnerReference, helper.NewConfigWithTimeout(0), opConfig, vss.NewVersionedSecretStore(client))
+
+				_, err := creator.Create(ctxlog.NewParentContext(log), qJob, qjv1a1.TriggerReasonManual, 1)
+				Expect(err).To(MatchError(ContainSubstring("set the template's serviceAccountName to 'persist-output'")))
+				Expect(job).To(BeNil())
+			})
+
+			Context("when the persist-output service account has a token secret", func() {
+				BeforeEach(func() {
+					serviceAccount = env.DefaultServiceAccount("persist-output", "default")
+				})
+
+				It("mounts the token secret into the output-persist container only", func() {
+					create()
+					Expect(job.Spec.Template.Spec.ServiceAccountName).To(BeEmpty())
+					Expect(job.Spec.Template.Spec.AutomountServiceAccountToken).To(BeNil())
+					Expect(volume(outputPersistMount().Name).Secret.SecretName).To(Equal("persist-output"))
+					for _, container := range job.Spec.Template.Spec.Containers[:1] {
+						for _, mount := range container.VolumeMounts {
+							Expect(mount.Name).ToNot(Equal(outputPersistMount().Name))
+						}
+					}
+				})
+			})
+		})
+
+		Context("when the template runs as the persist-output service account", func() {
+			BeforeEach(func() {
+				qJob.Spec.Template.Spec.Template.Spec.ServiceAccountName = "persist-output"
+			})
+
+			It("mounts a projected token and leaves the automount untouched", func() {
+				create()
+				Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal("persist-output"))
+				Expect(job.Spec.Template.Spec.AutomountServiceAccountToken).To(BeNil())
+				Expect(volume(outputPersistMount().Name).Projected).ToNot(BeNil())
+			})
+		})
+
+		Context("when the template has its own service account", func() {
+			BeforeEach(func() {
+				qJob.Spec.Template.Spec.Template.Spec.ServiceAccountName = "custom"
+				serviceAccount = env.DefaultServiceAccount("persist-output", "default")
+			})
+
+			It("leaves the service account untouched and mounts the token secret", func() {
+				create()
+				Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal("custom"))
+				Expect(job.Spec.Template.Spec.AutomountServiceAccountToken).To(BeNil())
+				Expect(volume(outputPersistMount().Name).Secret.SecretName).To(Equal("persist-output"))
+			})
+		})
+	})
+
+	Describe("restricted Pod Security Standard", func() {
+		Context("when the template is compliant", func() {
+			BeforeEach(func() {
+				qJob = env.RestrictedErrandQuarksJob("fake-qj", "default")
+				qJob.Spec.Output = &qjv1a1.Output{OutputMap: env.DefaultOutputMap()}
+			})
+
+			It("creates a compliant job", func() {
+				create()
+				Expect(testing.RestrictedPodSecurityViolations(job.Spec.Template.Spec)).To(BeEmpty())
+			})
+
+			It("limits the size of the output volumes", func() {
+				create()
+				volume := job.Spec.Template.Spec.Volumes[1]
+				Expect(volume.Name).To(Equal("output-busybox"))
+				Expect(volume.EmptyDir.SizeLimit.String()).To(Equal(operatorconfig.DefaultOutputVolumeSizeLimit))
+			})
+
+			Context("with projected service account tokens", func() {
+				BeforeEach(func() {
+					opConfig.ServiceAccountToken.Projected = true
+				})
+
+				It("creates a compliant job", func() {
+					create()
+					Expect(testing.RestrictedPodSecurityViolations(job.Spec.Template.Spec)).To(BeEmpty())
+				})
+			})
+
+			Context("when the quarks job overrides the sidecar security context", func() {
+				BeforeEach(func() {
+					qJob.Spec.Output.Sidecar = &qjv1a1.Sidecar{
+						SecurityContext: &corev1.SecurityContext{Privileged: pointers.Bool(true)},
+					}
+				})
+
+				It("reports the violations of the sidecar", func() {
+					create()
+					Expect(testing.RestrictedPodSecurityViolations(job.Spec.Template.Spec)).To(ConsistOf(
+						`container "output-persist": must not be privileged`,
+						`container "output-persist": allowPrivilegeEscalation must be false`,
+						`container "output-persist": must drop ALL capabilities`,
+					))
+				})
+			})
+		})
+
+		Context("when the template is not compliant", func() {
+			BeforeEach(func() {
+				qJob = env.ErrandQuarksJob("fake-qj", "default")
+			})
+
+			It("only reports violations of the template's containers", func() {
+				create()
+				violations := testing.RestrictedPodSecurityViolations(job.Spec.Template.Spec)
+				Expect(violations).To(ConsistOf(
+					`container "busybox": allowPrivilegeEscalation must be false`,
+					`container "busybox": must drop ALL capabilities`,
+					`container "busybox": runAsNonRoot must be true`,
+				))
+			})
+
+			It("defaults the pod's seccomp profile", func() {
+				create()
+				Expect(job.Spec.Template.Spec.SecurityContext.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+			})
+		})
+	})
+})
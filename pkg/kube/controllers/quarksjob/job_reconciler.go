@@ -2,12 +2,17 @@ package quarksjob
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -22,6 +27,9 @@ import (
 
 const (
 	// DeleteKind specify the kind of deleting resource.
+	//
+	// Deprecated: The label 'delete: pod' on the pod template deletes the
+	// pods of succeeded jobs, use Cleanup.OnSuccess.DeletePods instead.
 	DeleteKind = "pod"
 )
 
@@ -90,43 +98,239 @@ func (r *ReconcileJob) Reconcile(_ context.Context, request reconcile.Request) (
 		return reconcile.Result{}, errors.Wrapf(err, "getting parent quarksJob in Job Reconciler for job '%s/%s'", request.Namespace, instance.GetName())
 	}
 
-	// Delete Job if it succeeded
-	if instance.Status.Succeeded == 1 {
-		ctxlog.WithEvent(&qj, "DeletingJob").Infof(ctx, "Deleting succeeded job '%s/%s'", request.Namespace, instance.Name)
-		err = r.client.Delete(ctx, instance)
+	succeeded := instance.Status.Succeeded == 1
+	if !succeeded && !jobFailed(instance) {
+		return reconcile.Result{}, nil
+	}
+
+	// Requeues, e.g. for a delayed cleanup, may process the job after a
+	// newer run started. Only the latest run updates the quarks job.
+	latest, err := r.isLatestRun(ctx, &qj, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !latest {
+		ctxlog.Debugf(ctx, "Job '%s/%s' isn't the latest run of quarks job '%s', not updating its status", request.Namespace, instance.Name, qj.GetNamespacedName())
+	}
+
+	if !succeeded && latest {
+		wait, err := r.retry(ctx, &qj, instance)
 		if err != nil {
-			_ = ctxlog.WithEvent(instance, "DeleteError").Errorf(ctx, "Cannot delete succeeded job: '%s'", err)
-		}
-
-		if d, ok := instance.Spec.Template.Labels["delete"]; ok {
-			if d == DeleteKind {
-				pod, err := r.jobPod(ctx, instance.Name, instance.GetNamespace())
-				if err != nil {
-					_ = ctxlog.WithEvent(instance, "NotFoundError").Errorf(ctx, "Cannot find job's pod: '%s'", err)
-					return reconcile.Result{}, nil
-				}
-				ctxlog.WithEvent(&qj, "DeletingJobsPod").Infof(ctx, "Deleting succeeded job's pod '%s/%s'", pod.Namespace, pod.Name)
-				err = r.client.Delete(ctx, pod)
-				if err != nil {
-					_ = ctxlog.WithEvent(instance, "DeleteError").Errorf(ctx, "Cannot delete succeeded job's pod: '%s'", err)
-				}
-			}
+			return reconcile.Result{}, err
+		}
+		if wait > 0 {
+			// Keep the failed job until the next attempt was queued
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
+		if instance.Annotations[qjv1a1.AnnotationRetried] != "true" {
+			r.markFailed(ctx, &qj, instance)
+		}
+	}
+
+	result := reconcile.Result{}
+	policy := qj.Spec.Cleanup.Policy(succeeded)
+	if succeeded && deletePodsLabel(ctx, &qj, instance) {
+		policy.DeletePods = true
+	}
+	if limit := qj.Spec.JobsHistoryLimit(succeeded); limit != nil {
+		// The current job is kept as part of the history
+		policy.DeleteJob = false
+		err = r.pruneHistory(ctx, &qj, succeeded, int(*limit))
+		if err != nil {
+			_ = ctxlog.WithEvent(&qj, "DeleteError").Errorf(ctx, "Failed to prune job history of quarks job '%s': %s", qj.GetNamespacedName(), err)
+		}
+	}
+	if policy.DeleteJob || policy.DeletePods {
+		if wait := cleanupDelay(qj.Spec.Cleanup, instance); wait > 0 {
+			ctxlog.Debugf(ctx, "Delaying cleanup of job '%s/%s' for %s", request.Namespace, instance.Name, wait)
+			result.RequeueAfter = wait
+		} else {
+			r.cleanup(ctx, &qj, instance, policy, succeeded)
 		}
+	}
 
+	if succeeded && latest {
 		// Update QuarksJob status
 		qj.Status.Completed = true
+		if hash, ok := instance.Annotations[qjv1a1.AnnotationConfigHash]; ok {
+			qj.Status.SucceededConfigHash = hash
+		}
+		meta.SetStatusCondition(&qj.Status.Conditions, metav1.Condition{
+			Type:               qjv1a1.ConditionSucceeded,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: qj.Generation,
+			Reason:             "JobSucceeded",
+			Message:            fmt.Sprintf("Job '%s' succeeded", instance.Name),
+		})
 		err := r.client.Status().Update(ctx, &qj)
 		if err != nil {
 			_ = ctxlog.WithEvent(&qj, "UpdateError").Errorf(ctx, "Failed to update quarks job status '%s' (%s): %s", qj.GetNamespacedName(), qj.ResourceVersion, err)
 			return reconcile.Result{Requeue: false}, nil
 		}
+		r.notifyDependents(ctx, &qj)
 	}
 
-	return reconcile.Result{}, nil
+	return result, nil
 }
 
-// jobPod gets the job's pod. Only single-pod jobs are supported when persisting the output, so we just get the first one.
-func (r *ReconcileJob) jobPod(ctx context.Context, name string, namespace string) (*corev1.Pod, error) {
+// markFailed sets the succeeded condition of the quarks job to false, after
+// its job failed for good
+func (r *ReconcileJob) markFailed(ctx context.Context, qj *qjv1a1.QuarksJob, job *batchv1.Job) {
+	if meta.IsStatusConditionFalse(qj.Status.Conditions, qjv1a1.ConditionSucceeded) {
+		return
+	}
+	meta.SetStatusCondition(&qj.Status.Conditions, metav1.Condition{
+		Type:               qjv1a1.ConditionSucceeded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: qj.Generation,
+		Reason:             "JobFailed",
+		Message:            fmt.Sprintf("Job '%s' failed", job.Name),
+	})
+	if err := r.client.Status().Update(ctx, qj); err != nil {
+		_ = ctxlog.WithEvent(qj, "UpdateError").Errorf(ctx, "Failed to update quarks job status '%s' (%s): %s", qj.GetNamespacedName(), qj.ResourceVersion, err)
+	}
+}
+
+// cleanup deletes the finished job and its pods, as requested by the policy
+func (r *ReconcileJob) cleanup(ctx context.Context, qj *qjv1a1.QuarksJob, job *batchv1.Job, policy qjv1a1.CleanupPolicy, succeeded bool) {
+	state := "failed"
+	if succeeded {
+		state = "succeeded"
+	}
+
+	if policy.DeleteJob {
+		ctxlog.WithEvent(qj, "DeletingJob").Infof(ctx, "Deleting %s job '%s/%s'", state, job.Namespace, job.Name)
+		err := r.client.Delete(ctx, job)
+		if err != nil {
+			_ = ctxlog.WithEvent(job, "DeleteError").Errorf(ctx, "Cannot delete %s job: '%s'", state, err)
+		}
+	}
+
+	if policy.DeletePods {
+		pods, err := r.jobPods(ctx, job.Name, job.GetNamespace())
+		if err != nil {
+			_ = ctxlog.WithEvent(job, "NotFoundError").Errorf(ctx, "Cannot find job's pods: '%s'", err)
+			return
+		}
+		for i := range pods {
+			pod := &pods[i]
+			ctxlog.WithEvent(qj, "DeletingJobsPod").Infof(ctx, "Deleting %s job's pod '%s/%s'", state, pod.Namespace, pod.Name)
+			err = r.client.Delete(ctx, pod)
+			if err != nil {
+				_ = ctxlog.WithEvent(job, "DeleteError").Errorf(ctx, "Cannot delete %s job's pod: '%s'", state, err)
+			}
+		}
+	}
+}
+
+// isLatestRun returns true if no other job of the quarks job was created
+// after the job
+func (r *ReconcileJob) isLatestRun(ctx context.Context, qj *qjv1a1.QuarksJob, job *batchv1.Job) (bool, error) {
+	list := &batchv1.JobList{}
+	err := r.client.List(
+		ctx,
+		list,
+		client.InNamespace(qj.Namespace),
+		client.MatchingLabels(map[string]string{qjv1a1.LabelQJobName: qj.Name}),
+	)
+	if err != nil {
+		return false, errors.Wrapf(err, "listing jobs of quarks job '%s' failed", qj.GetNamespacedName())
+	}
+
+	for i := range list.Items {
+		other := &list.Items[i]
+		if other.Name != job.Name && job.CreationTimestamp.Before(&other.CreationTimestamp) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pruneHistory deletes the oldest finished jobs of the QuarksJob, which
+// are in the same state and exceed the history limit
+func (r *ReconcileJob) pruneHistory(ctx context.Context, qj *qjv1a1.QuarksJob, succeeded bool, limit int) error {
+	list := &batchv1.JobList{}
+	err := r.client.List(
+		ctx,
+		list,
+		client.InNamespace(qj.Namespace),
+		client.MatchingLabels(map[string]string{qjv1a1.LabelQJobName: qj.Name}),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "listing jobs of quarks job '%s' failed", qj.GetNamespacedName())
+	}
+
+	jobs := []batchv1.Job{}
+	for _, job := range list.Items {
+		if !job.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if (succeeded && job.Status.Succeeded == 1) || (!succeeded && jobFailed(&job)) {
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) <= limit {
+		return nil
+	}
+
+	// Newest first
+	sort.SliceStable(jobs, func(i, k int) bool {
+		return jobFinishedAt(&jobs[k]).Before(jobFinishedAt(&jobs[i]))
+	})
+
+	for i := limit; i < len(jobs); i++ {
+		job := &jobs[i]
+		ctxlog.WithEvent(qj, "PruningJob").Infof(ctx, "Deleting job '%s/%s', which exceeds the history limit of %d", job.Namespace, job.Name, limit)
+		err := r.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "deleting job '%s/%s' failed", job.Namespace, job.Name)
+		}
+	}
+
+	return nil
+}
+
+// jobDone returns true if the job succeeded or failed
+func jobDone(job *batchv1.Job) bool {
+	return job.Status.Succeeded == 1 || jobFailed(job)
+}
+
+// jobFailed returns true if the job will not be retried anymore
+func jobFailed(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return job.Spec.BackoffLimit != nil && job.Status.Failed > *job.Spec.BackoffLimit
+}
+
+// cleanupDelay returns how long to wait until the cleanup's TTL expired
+func cleanupDelay(cleanup *qjv1a1.Cleanup, job *batchv1.Job) time.Duration {
+	ttl := cleanup.TTL()
+	if ttl == 0 {
+		return 0
+	}
+
+	return time.Until(jobFinishedAt(job).Add(ttl))
+}
+
+// jobFinishedAt returns the time the job succeeded or failed, falls back to
+// the creation time
+func jobFinishedAt(job *batchv1.Job) time.Time {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return c.LastTransitionTime.Time
+		}
+	}
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime.Time
+	}
+	return job.GetCreationTimestamp().Time
+}
+
+// jobPods gets all pods of the job, there will be more than one if pods failed.
+func (r *ReconcileJob) jobPods(ctx context.Context, name string, namespace string) ([]corev1.Pod, error) {
 	list := &corev1.PodList{}
 	err := r.client.List(
 		ctx,
@@ -141,21 +345,17 @@ func (r *ReconcileJob) jobPod(ctx context.Context, name string, namespace string
 		return nil, errors.Errorf("Job '%s/%s' does not own any pods?", namespace, name)
 	}
 
-	// If there is only one job pod, then return index 0 pod.
-	latestPod := list.Items[0]
-	if len(list.Items) > 1 {
-		// If there are more than one job pods, then return the latest
-		// created job pod. There will be multiple job pods when job pods
-		// fail.
-		latestTimeStamp := list.Items[0].GetCreationTimestamp().UTC()
-		for podIndex, pod := range list.Items {
-			if latestTimeStamp.Before(pod.GetCreationTimestamp().UTC()) {
-				latestTimeStamp = pod.GetCreationTimestamp().UTC()
-				latestPod = list.Items[podIndex]
-			}
-		}
+	return list.Items, nil
+}
+
+// deletePodsLabel returns true if the job's pod template has the deprecated
+// 'delete: pod' label and the quarks job has no policy for succeeded jobs
+func deletePodsLabel(ctx context.Context, qJob *qjv1a1.QuarksJob, job *batchv1.Job) bool {
+	if job.Spec.Template.Labels["delete"] != DeleteKind {
+		return false
 	}
 
-	ctxlog.Infof(ctx, "Considering job pod '%s/%s' for persisting output", namespace, latestPod.GetName())
-	return &latestPod, nil
+	ctxlog.Debugf(ctx, "The label 'delete: %s' of quarks job '%s' is deprecated, use 'cleanup.onSuccess.deletePods' instead", DeleteKind, qJob.GetNamespacedName())
+
+	return qJob.Spec.Cleanup == nil || qJob.Spec.Cleanup.OnSuccess == nil
 }
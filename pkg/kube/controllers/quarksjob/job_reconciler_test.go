@@ -13,6 +13,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -26,6 +27,7 @@ import (
 	qj "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/quarksjob"
 	"code.cloudfoundry.org/quarks-job/testing"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
 )
 
@@ -41,6 +43,9 @@ var _ = Describe("ReconcileJob", func() {
 		pod1       *corev1.Pod
 		env        testing.Catalog
 		logs       *observer.ObservedLogs
+
+		statusWriter *cfakes.FakeStatusWriter
+		dependents   []qjv1a1.QuarksJob
 	)
 
 	BeforeEach(func() {
@@ -72,11 +77,17 @@ var _ = Describe("ReconcileJob", func() {
 			case *corev1.SecretList:
 				list := corev1.SecretList{}
 				list.DeepCopyInto(object)
+			case *qjv1a1.QuarksJobList:
+				list := qjv1a1.QuarksJobList{Items: dependents}
+				list.DeepCopyInto(object)
 			}
 			return nil
 		})
 		manager.GetClientReturns(client)
-		client.StatusCalls(func() crc.StatusWriter { return &cfakes.FakeStatusWriter{} })
+		manager.GetSchemeReturns(scheme.Scheme)
+		statusWriter = &cfakes.FakeStatusWriter{}
+		client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+		dependents = nil
 	})
 
 	JustBeforeEach(func() {
@@ -124,10 +135,9 @@ var _ = Describe("ReconcileJob", func() {
 		})
 
 		It("deletes owned pod together with the job", func() {
-			if job.Spec.Template.ObjectMeta.Labels == nil {
-				job.Spec.Template.ObjectMeta.Labels = map[string]string{}
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
 			}
-			job.Spec.Template.ObjectMeta.Labels["delete"] = qj.DeleteKind
 
 			_, err := reconciler.Reconcile(context.Background(), request)
 			Expect(err).ToNot(HaveOccurred())
@@ -135,14 +145,12 @@ var _ = Describe("ReconcileJob", func() {
 			Expect(client.StatusCallCount()).To(Equal(1))
 		})
 
-		It("deletes latest owned pod together with the job", func() {
-			var latestTimestamp metav1.Time
+		It("deletes all owned pods together with the job", func() {
 			client.ListCalls(func(context context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
 				switch object := object.(type) {
 				case *corev1.PodList:
 					pod2 := *pod1
-					latestTimestamp = metav1.Now()
-					pod2.SetCreationTimestamp(latestTimestamp)
+					pod2.Name = "foo-pod-2"
 					list := corev1.PodList{
 						Items: []corev1.Pod{*pod1, pod2},
 					}
@@ -153,24 +161,234 @@ var _ = Describe("ReconcileJob", func() {
 				}
 				return nil
 			})
-			client.DeleteCalls(func(context context.Context, object crc.Object, opts ...crc.DeleteOption) error {
-				switch pod := object.(type) {
-				case *corev1.Pod:
-					Expect(pod.GetCreationTimestamp()).To(Equal(latestTimestamp))
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
+			}
+
+			_, err := reconciler.Reconcile(context.Background(), request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(3))
+			_, object, _ := client.DeleteArgsForCall(2)
+			Expect(object.GetName()).To(Equal("foo-pod-2"))
+			Expect(client.StatusCallCount()).To(Equal(1))
+		})
+
+		It("keeps the job if the cleanup policy only deletes pods", func() {
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeletePods: true},
+			}
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+			_, object, _ := client.DeleteArgsForCall(0)
+			Expect(object).To(BeAssignableToTypeOf(&corev1.Pod{}))
+			Expect(client.StatusCallCount()).To(Equal(1))
+		})
+
+		It("keeps the job and its pods if cleanup is disabled", func() {
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{},
+			}
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(0))
+			Expect(client.StatusCallCount()).To(Equal(1))
+		})
+
+		It("delays the cleanup until the TTL expired", func() {
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{TTLSecondsAfterFinished: pointers.Int32(60)}
+			completed := metav1.NewTime(time.Now().Add(-10 * time.Second))
+			job.Status.CompletionTime = &completed
+
+			result, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(0))
+			Expect(client.StatusCallCount()).To(Equal(1))
+			Expect(result.RequeueAfter).To(BeNumerically("~", 50*time.Second, 5*time.Second))
+		})
+
+		It("cleans up once the TTL expired", func() {
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{TTLSecondsAfterFinished: pointers.Int32(60)}
+			completed := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+			job.Status.CompletionTime = &completed
+
+			result, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+
+		Context("when keeping a job history", func() {
+			var history []batchv1.Job
+
+			newJob := func(name string, age time.Duration, succeeded int32) batchv1.Job {
+				completed := metav1.NewTime(time.Now().Add(-age))
+				return batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: request.Namespace},
+					Spec:       batchv1.JobSpec{BackoffLimit: pointers.Int32(2)},
+					Status:     batchv1.JobStatus{Succeeded: succeeded, CompletionTime: &completed},
+				}
+			}
+
+			BeforeEach(func() {
+				history = []batchv1.Job{
+					newJob("foo-job-old", 3*time.Hour, 1),
+					newJob("foo-job-older", 4*time.Hour, 1),
+					newJob("foo-job-running", time.Hour, 0),
+					newJob("foo-job-newer", 2*time.Hour, 1),
+				}
+
+				client.ListCalls(func(context context.Context, object crc.ObjectList, opts ...crc.ListOption) error {
+					switch object := object.(type) {
+					case *batchv1.JobList:
+						listOpts := &crc.ListOptions{}
+						listOpts.ApplyOptions(opts)
+						Expect(listOpts.LabelSelector.String()).To(Equal(qjv1a1.LabelQJobName + "=foo"))
+
+						list := batchv1.JobList{Items: append([]batchv1.Job{*job}, history...)}
+						list.DeepCopyInto(object)
+					case *corev1.PodList:
+						list := corev1.PodList{Items: []corev1.Pod{*pod1}}
+						list.DeepCopyInto(object)
+					}
 					return nil
+				})
+			})
+
+			It("keeps the current job and prunes the oldest succeeded jobs", func() {
+				qJob.Spec.SuccessfulJobsHistoryLimit = pointers.Int32(2)
+				now := metav1.Now()
+				job.Status.CompletionTime = &now
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(2))
+
+				deleted := []string{}
+				for i := 0; i < client.DeleteCallCount(); i++ {
+					_, object, opts := client.DeleteArgsForCall(i)
+					deleted = append(deleted, object.GetName())
+					Expect(opts).To(ContainElement(crc.PropagationPolicy(metav1.DeletePropagationBackground)))
 				}
-				return nil
+				Expect(deleted).To(ConsistOf("foo-job-old", "foo-job-older"))
+				Expect(client.StatusCallCount()).To(Equal(1))
 			})
 
-			if job.Spec.Template.ObjectMeta.Labels == nil {
-				job.Spec.Template.ObjectMeta.Labels = map[string]string{}
-			}
-			job.Spec.Template.ObjectMeta.Labels["delete"] = qj.DeleteKind
+			It("still deletes the current job's pods if requested", func() {
+				qJob.Spec.SuccessfulJobsHistoryLimit = pointers.Int32(10)
+				qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+					OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
+				}
 
-			_, err := reconciler.Reconcile(context.Background(), request)
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(1))
+				_, object, _ := client.DeleteArgsForCall(0)
+				Expect(object).To(BeAssignableToTypeOf(&corev1.Pod{}))
+			})
+
+			Context("when the job isn't the latest run", func() {
+				JustBeforeEach(func() {
+					job.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+					history[2].CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+				})
+
+				It("doesn't update the quarks job", func() {
+					job.Annotations = map[string]string{qjv1a1.AnnotationConfigHash: "stale"}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.StatusCallCount()).To(Equal(0))
+				})
+
+				It("doesn't mark the quarks job as failed", func() {
+					job.Status.Succeeded = 0
+					job.Status.Failed = 3
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.StatusCallCount()).To(Equal(0))
+				})
+
+				It("still applies the cleanup policy", func() {
+					qJob.Spec.Cleanup = &qjv1a1.Cleanup{OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true}}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.DeleteCallCount()).To(Equal(1))
+				})
+			})
+
+			It("prunes failed jobs only by the failed jobs history limit", func() {
+				qJob.Spec.SuccessfulJobsHistoryLimit = pointers.Int32(0)
+				qJob.Spec.FailedJobsHistoryLimit = pointers.Int32(0)
+				job.Status.Succeeded = 0
+				job.Status.Failed = 3
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(1))
+				_, object, _ := client.DeleteArgsForCall(0)
+				Expect(object.GetName()).To(Equal("foo-job"))
+			})
+		})
+
+		It("marks the quarks job as succeeded", func() {
+			_, err := act()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(client.DeleteCallCount()).To(Equal(2))
-			Expect(client.StatusCallCount()).To(Equal(1))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			qj := object.(*qjv1a1.QuarksJob)
+			Expect(qj.Status.Completed).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(qj.Status.Conditions, qjv1a1.ConditionSucceeded)).To(BeTrue())
+		})
+
+		It("records the config hash of the successful run", func() {
+			job.Annotations = map[string]string{qjv1a1.AnnotationConfigHash: "abc123"}
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			Expect(object.(*qjv1a1.QuarksJob).Status.SucceededConfigHash).To(Equal("abc123"))
+		})
+
+		Context("when other quarks jobs depend on it", func() {
+			BeforeEach(func() {
+				dependents = []qjv1a1.QuarksJob{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "waiting", Namespace: "default"},
+						Spec:       qjv1a1.QuarksJobSpec{DependsOn: []qjv1a1.Dependency{{Name: "foo"}, {Name: "bar"}}},
+						Status:     qjv1a1.QuarksJobStatus{PendingRun: qjv1a1.TriggerReasonManual, WaitingFor: []string{"foo", "bar"}},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "chained", Namespace: "default"},
+						Spec:       qjv1a1.QuarksJobSpec{DependsOn: []qjv1a1.Dependency{{Name: "foo", Chain: true}}},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"},
+						Spec:       qjv1a1.QuarksJobSpec{DependsOn: []qjv1a1.Dependency{{Name: "bar", Chain: true}}},
+					},
+				}
+			})
+
+			It("stops waiting for it and queues runs of chained dependents", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(statusWriter.UpdateCallCount()).To(Equal(3))
+
+				_, object, _ := statusWriter.UpdateArgsForCall(1)
+				waiting := object.(*qjv1a1.QuarksJob)
+				Expect(waiting.Name).To(Equal("waiting"))
+				Expect(waiting.Status.WaitingFor).To(Equal([]string{"bar"}))
+				Expect(waiting.Status.PendingRun).To(Equal(qjv1a1.TriggerReasonManual))
+
+				_, object, _ = statusWriter.UpdateArgsForCall(2)
+				chained := object.(*qjv1a1.QuarksJob)
+				Expect(chained.Name).To(Equal("chained"))
+				Expect(chained.Status.PendingRun).To(Equal(qjv1a1.TriggerReasonDependency))
+			})
 		})
 
 		It("handles an error when getting job's quarks job reference failed", func() {
@@ -212,10 +430,9 @@ var _ = Describe("ReconcileJob", func() {
 		})
 
 		It("handles an error when deleting pod failed", func() {
-			if job.Spec.Template.ObjectMeta.Labels == nil {
-				job.Spec.Template.ObjectMeta.Labels = map[string]string{}
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
 			}
-			job.Spec.Template.ObjectMeta.Labels["delete"] = qj.DeleteKind
 
 			client.DeleteCalls(func(context context.Context, object crc.Object, opts ...crc.DeleteOption) error {
 				switch object.(type) {
@@ -230,11 +447,34 @@ var _ = Describe("ReconcileJob", func() {
 			Expect(logs.FilterMessageSnippet("Cannot delete succeeded job's pod").Len()).To(Equal(1))
 		})
 
+		It("deletes the pods if the pod template has the deprecated delete label", func() {
+			job.Spec.Template.Labels = map[string]string{"delete": "pod"}
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs.FilterMessageSnippet("is deprecated, use 'cleanup.onSuccess.deletePods' instead").Len()).To(Equal(1))
+			Expect(client.DeleteCallCount()).To(Equal(2))
+			_, object, _ := client.DeleteArgsForCall(1)
+			Expect(object).To(BeAssignableToTypeOf(&corev1.Pod{}))
+		})
+
+		It("prefers the cleanup policy over the deprecated delete label", func() {
+			job.Spec.Template.Labels = map[string]string{"delete": "pod"}
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true},
+			}
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+			_, object, _ := client.DeleteArgsForCall(0)
+			Expect(object).To(BeAssignableToTypeOf(&batchv1.Job{}))
+		})
+
 		It("handles an error when listing pod failed", func() {
-			if job.Spec.Template.ObjectMeta.Labels == nil {
-				job.Spec.Template.ObjectMeta.Labels = map[string]string{}
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
 			}
-			job.Spec.Template.ObjectMeta.Labels["delete"] = qj.DeleteKind
 
 			client.ListCalls(func(context context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
 				switch object := object.(type) {
@@ -249,15 +489,14 @@ var _ = Describe("ReconcileJob", func() {
 
 			_, err := reconciler.Reconcile(context.Background(), request)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(logs.FilterMessageSnippet("Cannot find job's pod").Len()).To(Equal(1))
+			Expect(logs.FilterMessageSnippet("Cannot find job's pods").Len()).To(Equal(1))
 			Expect(logs.FilterMessageSnippet(fmt.Sprintf("Listing job's '%s/%s' pods failed.", job.Namespace, job.Name)).Len()).To(Equal(1))
 		})
 
 		It("handles an error when pod list is empty", func() {
-			if job.Spec.Template.ObjectMeta.Labels == nil {
-				job.Spec.Template.ObjectMeta.Labels = map[string]string{}
+			qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+				OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
 			}
-			job.Spec.Template.ObjectMeta.Labels["delete"] = qj.DeleteKind
 
 			client.ListCalls(func(context context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
 				switch object := object.(type) {
@@ -273,7 +512,7 @@ var _ = Describe("ReconcileJob", func() {
 
 			_, err := reconciler.Reconcile(context.Background(), request)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(logs.FilterMessageSnippet("Cannot find job's pod").Len()).To(Equal(1))
+			Expect(logs.FilterMessageSnippet("Cannot find job's pods").Len()).To(Equal(1))
 			Expect(logs.FilterMessageSnippet(fmt.Sprintf("Job '%s/%s' does not own any pods?", job.Namespace, job.Name)).Len()).To(Equal(1))
 		})
 	})
@@ -290,5 +529,167 @@ var _ = Describe("ReconcileJob", func() {
 			Expect(client.DeleteCallCount()).To(Equal(0))
 		})
 
+		Context("when retries are exhausted", func() {
+			JustBeforeEach(func() {
+				job.Status.Failed = 3
+				job.Status.Conditions = []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()},
+				}
+			})
+
+			It("keeps the job and its pods by default", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(0))
+			})
+
+			It("marks the quarks job as failed", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+				_, object, _ := statusWriter.UpdateArgsForCall(0)
+				qj := object.(*qjv1a1.QuarksJob)
+				Expect(qj.Status.Completed).To(BeFalse())
+				Expect(meta.IsStatusConditionFalse(qj.Status.Conditions, qjv1a1.ConditionSucceeded)).To(BeTrue())
+			})
+
+			It("applies the failure cleanup policy", func() {
+				qJob.Spec.Cleanup = &qjv1a1.Cleanup{
+					OnSuccess: &qjv1a1.CleanupPolicy{DeleteJob: true, DeletePods: true},
+					OnFailure: &qjv1a1.CleanupPolicy{DeleteJob: true},
+				}
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(1))
+				_, object, _ := client.DeleteArgsForCall(0)
+				Expect(object).To(BeAssignableToTypeOf(&batchv1.Job{}))
+				Expect(logs.FilterMessageSnippet("Deleting failed job 'default/foo-job'").Len()).To(Equal(1))
+			})
+
+			Context("with a retry policy", func() {
+				var (
+					created  []*batchv1.Job
+					exitCode int32
+				)
+
+				BeforeEach(func() {
+					created = []*batchv1.Job{}
+					exitCode = 1
+
+					client.ListCalls(func(context context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+						switch object := object.(type) {
+						case *corev1.PodList:
+							pod := pod1.DeepCopy()
+							pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+								{Name: "busybox", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}}},
+								{Name: "output-persist", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+							}
+							list := corev1.PodList{Items: []corev1.Pod{*pod}}
+							list.DeepCopyInto(object)
+						}
+						return nil
+					})
+					client.CreateCalls(func(context context.Context, object crc.Object, _ ...crc.CreateOption) error {
+						if j, ok := object.(*batchv1.Job); ok {
+							created = append(created, j)
+						}
+						return nil
+					})
+				})
+
+				JustBeforeEach(func() {
+					qJob.Spec.Retry = &qjv1a1.Retry{MaxAttempts: 3, Backoff: &metav1.Duration{Duration: time.Minute}}
+					job.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+				})
+
+				It("queues the next attempt and marks the failed job", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(created).To(BeEmpty())
+
+					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+					_, object, _ := statusWriter.UpdateArgsForCall(0)
+					status := object.(*qjv1a1.QuarksJob).Status
+					Expect(status.PendingRun).To(Equal(qjv1a1.TriggerReasonRetry))
+					Expect(status.PendingAttempt).To(Equal(int32(2)))
+
+					_, object, _ = client.UpdateArgsForCall(0)
+					Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationRetried, "true"))
+					Expect(logs.FilterMessageSnippet("Retrying failed job 'default/foo-job', attempt 2 of 3").Len()).To(Equal(1))
+				})
+
+				It("waits for the backoff, which doubles with every attempt", func() {
+					job.Annotations = map[string]string{qjv1a1.AnnotationAttempt: "2"}
+					job.Status.Conditions[0].LastTransitionTime = metav1.Now()
+
+					result, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(created).To(BeEmpty())
+					Expect(result.RequeueAfter).To(BeNumerically("~", 2*time.Minute, 5*time.Second))
+				})
+
+				It("keeps the failed job until the next attempt was queued", func() {
+					qJob.Spec.Cleanup = &qjv1a1.Cleanup{OnFailure: &qjv1a1.CleanupPolicy{DeleteJob: true}}
+					job.Status.Conditions[0].LastTransitionTime = metav1.Now()
+
+					result, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, 5*time.Second))
+					Expect(client.DeleteCallCount()).To(Equal(0))
+				})
+
+				It("does not retry a job twice", func() {
+					job.Annotations = map[string]string{qjv1a1.AnnotationRetried: "true"}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(created).To(BeEmpty())
+					Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+				})
+
+				It("stops after the maximum number of attempts", func() {
+					job.Annotations = map[string]string{qjv1a1.AnnotationAttempt: "3"}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(created).To(BeEmpty())
+					Expect(logs.FilterMessageSnippet("reached maximum of 3 attempts").Len()).To(Equal(1))
+				})
+
+				It("only retries the configured exit codes", func() {
+					qJob.Spec.Retry.OnExitCodes = []int32{42}
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(logs.FilterMessageSnippet("exit codes [1] don't match the retry policy").Len()).To(Equal(1))
+
+					exitCode = 42
+					_, err = act()
+					Expect(err).ToNot(HaveOccurred())
+					_, object, _ := statusWriter.UpdateArgsForCall(statusWriter.UpdateCallCount() - 1)
+					Expect(object.(*qjv1a1.QuarksJob).Status.PendingRun).To(Equal(qjv1a1.TriggerReasonRetry))
+				})
+
+				It("doesn't replace a pending run", func() {
+					qJob.Status.PendingRun = qjv1a1.TriggerReasonConfigChange
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.UpdateCallCount()).To(Equal(0))
+					Expect(logs.FilterMessageSnippet("a 'config-change' run of quarks job 'default/foo' is already pending").Len()).To(Equal(1))
+				})
+
+				It("unmarks the failed job if queuing the next attempt failed", func() {
+					statusWriter.UpdateReturns(fmt.Errorf("fake-error"))
+
+					_, err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(client.UpdateCallCount()).To(Equal(2))
+					_, object, _ := client.UpdateArgsForCall(1)
+					Expect(object.GetAnnotations()).ToNot(HaveKey(qjv1a1.AnnotationRetried))
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,198 @@
+package quarksjob
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"go.mozilla.org/pkcs7"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+)
+
+const (
+	// EncryptionPKCS7 is the value of the encryption annotation for output
+	// encrypted as PKCS#7 enveloped data
+	EncryptionPKCS7 = "pkcs7"
+
+	pemTypePKCS7 = "PKCS7"
+)
+
+// outputEncrypter encrypts output values for the public key of a certificate.
+// A nil encrypter leaves the values untouched.
+type outputEncrypter struct {
+	certificates []*x509.Certificate
+}
+
+// newOutputEncrypter fetches the referenced certificate
+func (po *OutputPersistor) newOutputEncrypter(ctx context.Context, encryption *qjv1a1.Encryption) (*outputEncrypter, error) {
+	if encryption == nil {
+		return nil, nil
+	}
+
+	ref := encryption.CertificateRef
+	var value string
+	switch ref.Kind {
+	case "Secret":
+		secret, err := po.clientSet.CoreV1().Secrets(po.namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch encryption certificate secret '%s/%s'", po.namespace, ref.Name)
+		}
+		if v, ok := secret.Data[ref.Key]; ok {
+			value = string(v)
+		} else {
+			value = secret.StringData[ref.Key]
+		}
+	default:
+		configMap, err := po.clientSet.CoreV1().ConfigMaps(po.namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch encryption certificate config map '%s/%s'", po.namespace, ref.Name)
+		}
+		value = configMap.Data[ref.Key]
+	}
+
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.Errorf("no PEM encoded certificate found in key '%s' of '%s/%s'", ref.Key, po.namespace, ref.Name)
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse encryption certificate '%s/%s'", po.namespace, ref.Name)
+	}
+
+	return &outputEncrypter{certificates: []*x509.Certificate{certificate}}, nil
+}
+
+// encrypt returns the PEM encoded PKCS#7 enveloped data for each value
+func (e *outputEncrypter) encrypt(data map[string]string) (map[string]string, error) {
+	if e == nil {
+		return data, nil
+	}
+
+	result := make(map[string]string, len(data))
+	for key, value := range data {
+		encrypted, err := encryptAES256CBC([]byte(value), e.certificates)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encrypt value of '%s'", key)
+		}
+		result[key] = string(pem.EncodeToMemory(&pem.Block{Type: pemTypePKCS7, Bytes: encrypted}))
+	}
+	return result, nil
+}
+
+// annotate marks the annotations of encrypted output
+func (e *outputEncrypter) annotate(annotations map[string]string) map[string]string {
+	if e != nil {
+		annotations[qjv1a1.AnnotationEncryption] = EncryptionPKCS7
+	}
+	return annotations
+}
+
+// The ASN.1 structures of PKCS#7 enveloped data, see RFC 2315
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// encryptAES256CBC returns the PKCS#7 enveloped data of the content, like
+// pkcs7.Encrypt. The pkcs7 package picks the algorithm from a process-wide
+// global, which defaults to DES-CBC, so AES-256-CBC is used explicitly here.
+func encryptAES256CBC(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS#7 padding, a full block is added to aligned content
+	padding := aes.BlockSize - len(content)%aes.BlockSize
+	plaintext := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	encryptedContent, err := asn1.Marshal(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientInfos := make([]recipientInfo, len(recipients))
+	for i, recipient := range recipients {
+		publicKey, ok := recipient.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("unsupported public key of certificate '%s', only RSA is supported", recipient.Subject.CommonName)
+		}
+		encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, key)
+		if err != nil {
+			return nil, err
+		}
+		recipientInfos[i] = recipientInfo{
+			IssuerAndSerialNumber: issuerAndSerial{
+				IssuerName:   asn1.RawValue{FullBytes: recipient.RawIssuer},
+				SerialNumber: recipient.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: pkcs7.OIDEncryptionAlgorithmRSA},
+			EncryptedKey:           encryptedKey,
+		}
+	}
+
+	envelope, err := asn1.Marshal(envelopedData{
+		RecipientInfos: recipientInfos,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType: pkcs7.OIDData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  pkcs7.OIDEncryptionAlgorithmAES256CBC,
+				Parameters: asn1.RawValue{Tag: asn1.TagOctetString, Bytes: iv},
+			},
+			EncryptedContent: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: encryptedContent},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: pkcs7.OIDEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: envelope},
+	})
+}
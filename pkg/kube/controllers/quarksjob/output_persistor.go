@@ -2,11 +2,18 @@ package quarksjob
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -15,6 +22,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	"code.cloudfoundry.org/quarks-job/pkg/kube/client/clientset/versioned"
@@ -31,6 +39,35 @@ type OutputPersistor struct {
 	clientSet            kubernetes.Interface
 	versionedClientSet   versioned.Interface
 	outputFilePathPrefix string
+	exposed              *exposedOutputs
+	encrypter            *outputEncrypter
+}
+
+// exposedOutputs collects the output values, which are copied to the
+// QuarksJob's status after persisting
+type exposedOutputs struct {
+	sync.Mutex
+	outputs map[string]map[string]string
+}
+
+// add copies the values of the given keys from data
+func (e *exposedOutputs) add(name string, keys []string, data map[string]string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		if e.outputs[name] == nil {
+			e.outputs[name] = map[string]string{}
+		}
+		e.outputs[name][key] = value
+	}
 }
 
 // NewOutputPersistor returns a persist output interface which can create kubernetes secrets.
@@ -42,6 +79,7 @@ func NewOutputPersistor(log *zap.SugaredLogger, namespace string, podName string
 		clientSet:            clientSet,
 		versionedClientSet:   versionedClientSet,
 		outputFilePathPrefix: outputFilePathPrefix,
+		exposed:              &exposedOutputs{outputs: map[string]map[string]string{}},
 	}
 }
 
@@ -63,8 +101,28 @@ func (po *OutputPersistor) Persist(ctx context.Context) error {
 		return errors.Wrapf(err, "failed to fetch qJob")
 	}
 
+	// Stop waiting for output once the run timeout is exceeded
+	if qJob.Spec.RunTimeout != nil && pod.Status.StartTime != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, pod.Status.StartTime.Add(qJob.Spec.RunTimeout.Duration))
+		defer cancel()
+	}
+
 	// Persist output if needed
 	if !reflect.DeepEqual(qjv1a1.Output{}, qJob.Spec.Output) && qJob.Spec.Output != nil {
+		po.encrypter, err = po.newOutputEncrypter(ctx, qJob.Spec.Output.Encryption)
+		if err != nil {
+			return err
+		}
+
+		if value, ok := pod.Annotations[qjv1a1.AnnotationDefaultSecretLabels]; ok {
+			labels := map[string]string{}
+			if err := json.Unmarshal([]byte(value), &labels); err != nil {
+				return errors.Wrapf(err, "failed to parse default secret labels of pod %s", po.podName)
+			}
+			qJob.Spec.Output.SecretLabels = mergeMissing(qJob.Spec.Output.SecretLabels, labels)
+		}
+
 		err = po.persistPod(ctx, pod, qJob)
 		if err != nil {
 			return err
@@ -78,25 +136,57 @@ func (po *OutputPersistor) persistPod(ctx context.Context, pod *corev1.Pod, qJob
 	errorContainerChannel := make(chan error)
 
 	// Loop over containers and create go routine
+	routines := 0
 	for containerIndex, container := range pod.Spec.Containers {
-		if container.Name == "output-persist" {
+		if container.Name == outputPersistContainerName {
 			continue
 		}
 
-		filesToSecrets, found := qJob.Spec.Output.OutputMap[container.Name]
-		if !found {
-			continue
+		if filesToSecrets, found := qJob.Spec.Output.OutputMap[container.Name]; found {
+			go po.persistContainer(ctx, qJob, containerIndex, container, filesToSecrets, errorContainerChannel)
+			routines++
 		}
 
-		go po.persistContainer(ctx, qJob, containerIndex, container, filesToSecrets, errorContainerChannel)
+		if logOptions, found := qJob.Spec.Output.LogMap[container.Name]; found {
+			go po.persistContainerLog(ctx, qJob, containerIndex, container, logOptions, errorContainerChannel)
+			routines++
+		}
 	}
 
 	// wait for all container go routines
-	for i := 0; i < len(pod.Spec.Containers)-1; i++ {
-		err := <-errorContainerChannel
+	for i := 0; i < routines; i++ {
+		select {
+		case err := <-errorContainerChannel:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "run timeout of qJob '%s' exceeded while waiting for output of pod '%s/%s'", qJob.Name, po.namespace, po.podName)
+		}
+	}
+
+	return po.updateStatusOutputs(ctx, qJob.Name)
+}
+
+// updateStatusOutputs copies the exposed output values to the QuarksJob's status
+func (po *OutputPersistor) updateStatusOutputs(ctx context.Context, qJobName string) error {
+	if len(po.exposed.outputs) == 0 {
+		return nil
+	}
+
+	qJobClient := po.versionedClientSet.QuarksjobV1alpha1().QuarksJobs(po.namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		qJob, err := qJobClient.Get(ctx, qJobName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
+
+		qJob.Status.Outputs = po.exposed.outputs
+		_, err = qJobClient.UpdateStatus(ctx, qJob, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update outputs in status of qJob '%s/%s'", po.namespace, qJobName)
 	}
 	return nil
 }
@@ -112,7 +202,7 @@ func (po *OutputPersistor) persistContainer(
 	errorContainerChannel chan<- error,
 ) {
 	prefix := filepath.Join(po.outputFilePathPrefix, container.Name)
-	filePaths := filesToSecrets.PrefixedPaths(prefix)
+	filePaths := filesToSecrets.RequiredPaths(prefix)
 	po.log.Debugf("container '%s': expects outputs in %v", container.Name, filePaths)
 
 	containerIndex, err := po.checkForOutputFiles(filePaths, containerIndex, container.Name)
@@ -120,14 +210,20 @@ func (po *OutputPersistor) persistContainer(
 		errorContainerChannel <- err
 	}
 	if containerIndex != -1 {
-		exitCode, err := po.getContainerExitCode(ctx, containerIndex)
+		pod, status, err := po.getTerminatedContainer(ctx, containerIndex)
 		if err != nil {
 			errorContainerChannel <- err
+			return
 		}
+		exitCode := status.State.Terminated.ExitCode
 		if exitCode == 0 || (exitCode == 1 && qJob.Spec.Output.WriteOnFailure) {
-			for fileName, options := range filesToSecrets {
-				filePath := filepath.Join(prefix, fileName)
+			outputFiles, err := expandOutputFiles(prefix, filesToSecrets)
+			if err != nil {
+				errorContainerChannel <- errors.Wrapf(err, "failed to find output files in container %s in pod '%s/%s'", container.Name, po.namespace, po.podName)
+				return
+			}
 
+			for filePath, options := range outputFiles {
 				if options.AdditionalSecretLabels == nil {
 					options.AdditionalSecretLabels = map[string]string{}
 				}
@@ -154,11 +250,18 @@ func (po *OutputPersistor) persistContainer(
 						if err := json.Unmarshal([]byte(value), &stringData); err != nil {
 							errorContainerChannel <- err
 						}
+						annotations := po.encrypter.annotate(runAnnotations(qJob, pod, status, options.AdditionalSecretAnnotations, stringData))
+						stringData, err = po.encrypter.encrypt(stringData)
+						if err != nil {
+							errorContainerChannel <- err
+							return
+						}
 
+						po.exposed.add(name, options.ExposeInStatus, stringData)
 						if options.Versioned {
-							err = po.createVersionedSecret(qJob, name, labels, options.AdditionalSecretAnnotations, stringData)
+							err = po.createVersionedSecret(qJob, name, labels, annotations, stringData)
 						} else {
-							err = po.createSecret(ctx, name, labels, options.AdditionalSecretAnnotations, stringData)
+							err = po.createSecret(ctx, name, labels, annotations, stringData)
 						}
 						if err != nil {
 							errorContainerChannel <- errors.Wrapf(err, "failed to persist qjob '%s' output, pod '%s/%s', container '%s', using fan-out", qJob.Name, po.namespace, po.podName, container.Name)
@@ -168,11 +271,17 @@ func (po *OutputPersistor) persistContainer(
 				default:
 					name := names.SanitizeSubdomain(options.Name)
 					po.log.Debugf("container '%s': creating secret '%s' from '%s'", container.Name, name, filePath)
-					var err error
+					annotations := po.encrypter.annotate(runAnnotations(qJob, pod, status, options.AdditionalSecretAnnotations, data))
+					data, err := po.encrypter.encrypt(data)
+					if err != nil {
+						errorContainerChannel <- err
+						return
+					}
+					po.exposed.add(name, options.ExposeInStatus, data)
 					if options.Versioned {
-						err = po.createVersionedSecret(qJob, name, labels, options.AdditionalSecretAnnotations, data)
+						err = po.createVersionedSecret(qJob, name, labels, annotations, data)
 					} else {
-						err = po.createSecret(ctx, name, labels, options.AdditionalSecretAnnotations, data)
+						err = po.createSecret(ctx, name, labels, annotations, data)
 					}
 					if err != nil {
 						errorContainerChannel <- errors.Wrapf(err, "failed to persist qjob '%s' output, pod '%s/%s', container '%s', using one-to-one", qJob.Name, po.namespace, po.podName, container.Name)
@@ -185,17 +294,140 @@ func (po *OutputPersistor) persistContainer(
 	errorContainerChannel <- err
 }
 
-// getContainerExitCode returns the exit code of the container
-func (po *OutputPersistor) getContainerExitCode(ctx context.Context, containerIndex int) (int, error) {
+// expandOutputFiles maps the paths of all output files to their secret
+// options. Patterns are expanded, each matching file results in its own
+// secret. Missing optional files are skipped. Matches, which would be
+// persisted to the same secret, are an error.
+func expandOutputFiles(prefix string, filesToSecrets qjv1a1.FilesToSecrets) (map[string]qjv1a1.SecretOptions, error) {
+	result := map[string]qjv1a1.SecretOptions{}
+	// matched files by the name of their secret
+	matched := map[string]string{}
+	for fileName, options := range filesToSecrets {
+		filePath := filepath.Join(prefix, fileName)
+
+		if !qjv1a1.IsPattern(fileName) {
+			if options.Optional && !fileExists(filePath) {
+				continue
+			}
+			result[filePath] = options
+			continue
+		}
+
+		pattern := filePath
+		if strings.HasSuffix(fileName, "/") {
+			pattern = filepath.Join(filePath, "*")
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid output file pattern '%s'", fileName)
+		}
+
+		found := false
+		for _, match := range matches {
+			if !fileExists(match) {
+				continue
+			}
+			matchOptions := options
+			matchOptions.Name = options.StemName(match)
+			name := names.SanitizeSubdomain(matchOptions.Name)
+			if other, ok := matched[name]; ok && other != match {
+				files := []string{other, match}
+				sort.Strings(files)
+				return nil, errors.Errorf("output files '%s' and '%s' would both be persisted to secret '%s'", files[0], files[1], name)
+			}
+			matched[name] = match
+			result[match] = matchOptions
+			found = true
+		}
+		if !found && !options.Optional {
+			return nil, errors.Errorf("no output files match '%s'", fileName)
+		}
+	}
+	return result, nil
+}
+
+// persistContainerLog waits for the container to terminate and persists
+// the tail of its log into a secret or config map
+func (po *OutputPersistor) persistContainerLog(
+	ctx context.Context,
+	qJob *qjv1a1.QuarksJob,
+	containerIndex int,
+	container corev1.Container,
+	options qjv1a1.LogOptions,
+	errorContainerChannel chan<- error,
+) {
+	pod, status, err := po.getTerminatedContainer(ctx, containerIndex)
+	if err != nil {
+		errorContainerChannel <- err
+		return
+	}
+	exitCode := status.State.Terminated.ExitCode
+	if exitCode != 0 && !(exitCode == 1 && qJob.Spec.Output.WriteOnFailure) {
+		errorContainerChannel <- nil
+		return
+	}
+
+	logOptions := &corev1.PodLogOptions{Container: container.Name, TailLines: options.TailLines}
+	log, err := po.clientSet.CoreV1().Pods(po.namespace).GetLogs(po.podName, logOptions).DoRaw(ctx)
+	if err != nil {
+		errorContainerChannel <- errors.Wrapf(err, "failed to fetch log of container '%s' in pod '%s/%s'", container.Name, po.namespace, po.podName)
+		return
+	}
+
+	data := map[string]string{qjv1a1.LogKey: string(log)}
+	if options.ParseJSON {
+		data, err = lastLineJSON(log)
+		if err != nil {
+			errorContainerChannel <- errors.Wrapf(err, "failed to convert last log line of container '%s' into json in pod '%s/%s'", container.Name, po.namespace, po.podName)
+			return
+		}
+	}
+
+	annotations := po.encrypter.annotate(runAnnotations(qJob, pod, status, options.AdditionalSecretAnnotations, data))
+	data, err = po.encrypter.encrypt(data)
+	if err != nil {
+		errorContainerChannel <- err
+		return
+	}
+
+	labels := newLabels(*qJob.Spec.Output, options.AdditionalSecretLabels, container)
+	name := names.SanitizeSubdomain(options.Name)
+
+	switch options.Target {
+	case qjv1a1.TargetConfigMap:
+		po.log.Debugf("container '%s': creating config map '%s' from log", container.Name, name)
+		err = po.createConfigMap(ctx, name, labels, annotations, data)
+	default:
+		po.log.Debugf("container '%s': creating secret '%s' from log", container.Name, name)
+		err = po.createSecret(ctx, name, labels, annotations, data)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "failed to persist qjob '%s' log, pod '%s/%s', container '%s'", qJob.Name, po.namespace, po.podName, container.Name)
+	}
+
+	errorContainerChannel <- err
+}
+
+// lastLineJSON parses the last non-empty line of the log as a JSON object
+func lastLineJSON(log []byte) (map[string]string, error) {
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	var data map[string]string
+	err := json.Unmarshal([]byte(lines[len(lines)-1]), &data)
+	return data, err
+}
+
+// getTerminatedContainer waits for the container to terminate and returns
+// the pod together with the container's status
+func (po *OutputPersistor) getTerminatedContainer(ctx context.Context, containerIndex int) (*corev1.Pod, corev1.ContainerStatus, error) {
 	// Wait until the container gets into terminated state
 	for {
 		pod, err := po.clientSet.CoreV1().Pods(po.namespace).Get(ctx, po.podName, metav1.GetOptions{})
 		if err != nil {
-			return -1, errors.Wrapf(err, "failed to fetch pod '%s/%s'", po.namespace, po.podName)
+			return nil, corev1.ContainerStatus{}, errors.Wrapf(err, "failed to fetch pod '%s/%s'", po.namespace, po.podName)
 		}
 		for _, containerStatus := range pod.Status.ContainerStatuses {
 			if containerStatus.Name == pod.Spec.Containers[containerIndex].Name && containerStatus.State.Terminated != nil {
-				return int(containerStatus.State.Terminated.ExitCode), nil
+				return pod, containerStatus, nil
 			}
 		}
 	}
@@ -217,44 +449,56 @@ func (po *OutputPersistor) checkForOutputFiles(filePaths []string, containerInde
 	}
 	defer watcher.Close()
 
-	createEventFileChannel := make(chan int)
-	errorEventFileChannel := make(chan error)
+	root := filepath.Join(po.outputFilePathPrefix, containerName)
+	for {
+		// Files in nested directories don't create events in the
+		// container's directory, watch their parents once they exist
+		if err := watchDirs(watcher, root, filePaths); err != nil {
+			return -1, err
+		}
+		seen.checkAll()
+		if seen.complete() {
+			return containerIndex, nil
+		}
 
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					continue
-				}
-				po.log.Debugf("container '%s': new event for %s", containerName, event.Name)
-				if event.Op == fsnotify.Create && seen.requires(event.Name) {
-					po.log.Debugf("container '%s': event for %s", containerName, event.Name)
-					seen.done(event.Name)
-				}
-				if seen.complete() {
-					createEventFileChannel <- containerIndex
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					continue
-				}
-				errorEventFileChannel <- err
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return -1, errors.Errorf("watcher for container '%s' closed", containerName)
+			}
+			po.log.Debugf("container '%s': new event for %s", containerName, event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return -1, errors.Errorf("watcher for container '%s' closed", containerName)
 			}
+			return -1, err
 		}
-	}()
+	}
+}
 
-	err = watcher.Add(filepath.Join(po.outputFilePathPrefix, containerName))
-	if err != nil {
-		return -1, err
+// watchDirs adds the root and all existing directories between the root and
+// the files to the watcher
+func watchDirs(watcher *fsnotify.Watcher, root string, filePaths []string) error {
+	if err := watcher.Add(root); err != nil {
+		return err
 	}
 
-	select {
-	case containerIndex := <-createEventFileChannel:
-		return containerIndex, nil
-	case err := <-errorEventFileChannel:
-		return -1, err
+	dirs := map[string]struct{}{}
+	for _, filePath := range filePaths {
+		for dir := filepath.Dir(filePath); strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	for dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "failed to watch directory '%s'", dir)
+		}
 	}
+	return nil
 }
 
 type seen map[string]bool
@@ -267,13 +511,6 @@ func newSeen(files []string) seen {
 	return s
 }
 
-func (s seen) requires(key string) bool {
-	if _, found := s[key]; found {
-		return true
-	}
-	return false
-}
-
 func (s seen) done(file string) {
 	s[file] = true
 }
@@ -319,6 +556,54 @@ func newLabels(output qjv1a1.Output, additionalSecretLabels map[string]string, c
 	return labels
 }
 
+// runAnnotations returns the annotations for persisted output, describing
+// the run which produced it
+func runAnnotations(qJob *qjv1a1.QuarksJob, pod *corev1.Pod, status corev1.ContainerStatus, additionalAnnotations map[string]string, data map[string]string) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range additionalAnnotations {
+		annotations[k] = v
+	}
+	annotations[qjv1a1.AnnotationQJobName] = qJob.Name
+	annotations[qjv1a1.AnnotationQJobUID] = string(qJob.UID)
+	annotations[qjv1a1.AnnotationJobName] = pod.Labels["job-name"]
+	annotations[qjv1a1.AnnotationPodName] = pod.Name
+	annotations[qjv1a1.AnnotationImageDigest] = status.ImageID
+	if terminated := status.State.Terminated; terminated != nil {
+		annotations[qjv1a1.AnnotationStartedAt] = terminated.StartedAt.UTC().Format(time.RFC3339)
+		annotations[qjv1a1.AnnotationFinishedAt] = terminated.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	annotations[qjv1a1.AnnotationChecksum] = checksum(data)
+	return annotations
+}
+
+// outputUnchanged returns true if the existing output has the labels and was
+// persisted from the same content. Encrypted values differ on every run, so
+// the checksum of the plaintext is compared instead of the data.
+func outputUnchanged(existing metav1.ObjectMeta, labels map[string]string, annotations map[string]string) bool {
+	for k, v := range labels {
+		if existing.Labels[k] != v {
+			return false
+		}
+	}
+	return existing.Annotations[qjv1a1.AnnotationChecksum] == annotations[qjv1a1.AnnotationChecksum] &&
+		existing.Annotations[qjv1a1.AnnotationEncryption] == annotations[qjv1a1.AnnotationEncryption]
+}
+
+// checksum returns the sha256 of the data, independent of key order
+func checksum(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", k, data[k])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func (po *OutputPersistor) createVersionedSecret(
 	qJob *qjv1a1.QuarksJob,
 	name string,
@@ -332,6 +617,11 @@ func (po *OutputPersistor) createVersionedSecret(
 	sourceDescription := "created by quarksJob"
 
 	store := versionedsecretstore.NewClientsetVersionedSecretStore(po.clientSet)
+	if latest, err := store.Latest(context.Background(), po.namespace, name); err == nil && outputUnchanged(latest.ObjectMeta, labels, annotations) {
+		// No-op, the latest version was persisted from the same content
+		return nil
+	}
+
 	err := store.Create(context.Background(), po.namespace, ownerName, ownerID, ownerKind, name, data, annotations, labels, sourceDescription)
 	if err != nil {
 		if !versionedsecretstore.IsSecretIdenticalError(err) {
@@ -366,6 +656,11 @@ func (po *OutputPersistor) createSecret(
 
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
+			existing, err := po.clientSet.CoreV1().Secrets(po.namespace).Get(ctx, name, metav1.GetOptions{})
+			if err == nil && outputUnchanged(existing.ObjectMeta, labels, annotations) {
+				return nil
+			}
+
 			// If it exists update it
 			_, err = po.clientSet.CoreV1().Secrets(po.namespace).Update(ctx, secret, metav1.UpdateOptions{})
 			if err != nil {
@@ -378,3 +673,43 @@ func (po *OutputPersistor) createSecret(
 
 	return nil
 }
+
+// createConfigMap creates or updates a config map for a given container
+func (po *OutputPersistor) createConfigMap(
+	ctx context.Context,
+	name string,
+	labels map[string]string,
+	annotations map[string]string,
+	data map[string]string,
+) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   po.namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Data: data,
+	}
+
+	_, err := po.clientSet.CoreV1().ConfigMaps(po.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			existing, err := po.clientSet.CoreV1().ConfigMaps(po.namespace).Get(ctx, name, metav1.GetOptions{})
+			if err == nil && outputUnchanged(existing.ObjectMeta, labels, annotations) {
+				return nil
+			}
+
+			// If it exists update it
+			_, err = po.clientSet.CoreV1().ConfigMaps(po.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+			if err != nil {
+				return errors.Wrapf(err, "failed to update config map '%s'", name)
+			}
+		} else {
+			return errors.Wrapf(err, "failed to create config map '%s'", name)
+		}
+	}
+
+	return nil
+}
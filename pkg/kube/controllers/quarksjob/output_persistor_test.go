@@ -2,13 +2,21 @@ package quarksjob_test
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"go.mozilla.org/pkcs7"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -60,6 +68,25 @@ var _ = Describe("OutputPersistor", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	Context("when the run timeout is exceeded", func() {
+		BeforeEach(func() {
+			started := metav1.NewTime(time.Now().Add(-time.Minute))
+			pod.Status.StartTime = &started
+			qJob.Spec.RunTimeout = &metav1.Duration{Duration: 30 * time.Second}
+			qJob.Spec.Output = &qjv1a1.Output{
+				OutputMap: qjv1a1.OutputMap{
+					"busybox": qjv1a1.NewFileToSecret("output.json", "foo-busybox", false, nil, nil),
+				},
+			}
+		})
+
+		It("stops waiting for output files", func() {
+			err := po.Persist(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("run timeout of qJob 'foo' exceeded"))
+		})
+	})
+
 	Context("when persisting one output", func() {
 		JustBeforeEach(func() {
 			// Create output file
@@ -118,6 +145,35 @@ var _ = Describe("OutputPersistor", func() {
 						"key":                                    "value"}))
 				})
 
+				Context("when quarks job defaults add secret labels", func() {
+					BeforeEach(func() {
+						pod.Annotations = map[string]string{
+							qjv1a1.AnnotationDefaultSecretLabels: `{"key":"default","team":"platform"}`,
+						}
+					})
+
+					It("adds the labels, which are not set by the quarks job", func() {
+						err := po.Persist(context.Background())
+						Expect(err).NotTo(HaveOccurred())
+						secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox", metav1.GetOptions{})
+						Expect(err).NotTo(HaveOccurred())
+						Expect(secret.Labels).To(HaveKeyWithValue("key", "value"))
+						Expect(secret.Labels).To(HaveKeyWithValue("team", "platform"))
+					})
+				})
+
+				It("annotates the secret with the run metadata", func() {
+					err := po.Persist(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(secret.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationQJobName, "foo"))
+					Expect(secret.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationJobName, "foo-job"))
+					Expect(secret.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationPodName, "foo-pod"))
+					Expect(secret.Annotations).To(HaveKey(qjv1a1.AnnotationFinishedAt))
+					Expect(secret.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationChecksum, "61df1427227256b0d75a65ba61fadd3316e5008dbcb55eff3553a73a415ea515"))
+				})
+
 				Context("when the output file is not json valid", func() {
 					BeforeEach(func() {
 						// Create faulty output file
@@ -138,6 +194,115 @@ var _ = Describe("OutputPersistor", func() {
 				})
 			})
 
+			Context("when output values are exposed in the status", func() {
+				BeforeEach(func() {
+					qJob.Spec.Output = &qjv1a1.Output{
+						OutputMap: qjv1a1.OutputMap{
+							"busybox": qjv1a1.FilesToSecrets{
+								"output.json": qjv1a1.SecretOptions{
+									Name:           "foo-busybox",
+									ExposeInStatus: []string{"hello", "missing"},
+								},
+							},
+						},
+					}
+				})
+
+				It("copies the values to the quarks job status", func() {
+					err := po.Persist(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					qJob, err := versionedClientSet.QuarksjobV1alpha1().QuarksJobs(namespace).Get(context.Background(), "foo", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(qJob.Status.Outputs).To(Equal(map[string]map[string]string{
+						"foo-busybox": {"hello": "world"},
+					}))
+				})
+			})
+
+			Context("when output encryption is configured", func() {
+				var (
+					certificate *x509.Certificate
+					key         *rsa.PrivateKey
+				)
+
+				BeforeEach(func() {
+					var err error
+					key, err = rsa.GenerateKey(rand.Reader, 2048)
+					Expect(err).NotTo(HaveOccurred())
+					template := &x509.Certificate{
+						SerialNumber: big.NewInt(1),
+						Subject:      pkix.Name{CommonName: "output"},
+						NotBefore:    time.Now(),
+						NotAfter:     time.Now().Add(time.Hour),
+					}
+					der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+					Expect(err).NotTo(HaveOccurred())
+					certificate, err = x509.ParseCertificate(der)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = clientSet.CoreV1().ConfigMaps(namespace).Create(context.Background(), &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: "output-cert", Namespace: namespace},
+						Data:       map[string]string{"cert.pem": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))},
+					}, metav1.CreateOptions{})
+					Expect(err).NotTo(HaveOccurred())
+
+					qJob.Spec.Output = &qjv1a1.Output{
+						OutputMap: qjv1a1.OutputMap{
+							"busybox": qjv1a1.NewFileToSecret("output.json", "foo-busybox", false, nil, nil),
+						},
+						Encryption: &qjv1a1.Encryption{
+							CertificateRef: qjv1a1.KeyReference{Name: "output-cert", Key: "cert.pem"},
+						},
+					}
+				})
+
+				It("encrypts the values for the certificate", func() {
+					err := po.Persist(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(secret.Annotations).To(HaveKeyWithValue(qjv1a1.AnnotationEncryption, quarksjob.EncryptionPKCS7))
+
+					block, _ := pem.Decode([]byte(secret.StringData["hello"]))
+					Expect(block).NotTo(BeNil())
+					p7, err := pkcs7.Parse(block.Bytes)
+					Expect(err).NotTo(HaveOccurred())
+					value, err := p7.Decrypt(certificate, key)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(value)).To(Equal("world"))
+					Expect(pkcs7.ContentEncryptionAlgorithm).To(Equal(pkcs7.EncryptionAlgorithmDESCBC), "the pkcs7 default must not be changed")
+				})
+
+				It("keeps the secret, if the output didn't change", func() {
+					Expect(po.Persist(context.Background())).To(Succeed())
+					secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(po.Persist(context.Background())).To(Succeed())
+					persisted, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox", metav1.GetOptions{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(persisted.StringData).To(Equal(secret.StringData))
+				})
+
+				Context("when the output is versioned", func() {
+					BeforeEach(func() {
+						options := qJob.Spec.Output.OutputMap["busybox"]["output.json"]
+						options.Versioned = true
+						qJob.Spec.Output.OutputMap["busybox"]["output.json"] = options
+					})
+
+					It("doesn't create a new version, if the output didn't change", func() {
+						Expect(po.Persist(context.Background())).To(Succeed())
+						Expect(po.Persist(context.Background())).To(Succeed())
+
+						_, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox-v1", metav1.GetOptions{})
+						Expect(err).NotTo(HaveOccurred())
+						_, err = clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-busybox-v2", metav1.GetOptions{})
+						Expect(err).To(HaveOccurred())
+					})
+				})
+			})
+
 			Context("when versioned output is enabled", func() {
 				additionalLabels := map[string]string{
 					"quarks.cloudfoundry.org/entanglement": "foo-busybox",
@@ -381,4 +546,238 @@ var _ = Describe("OutputPersistor", func() {
 			})
 		})
 	})
+
+	Context("when persisting outputs matched by patterns", func() {
+		BeforeEach(func() {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{
+					Name: "busybox",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 0},
+					},
+				},
+			}
+
+			Expect(os.Mkdir(filepath.Join(tmpDir, "busybox", "certs"), 0755)).ToNot(HaveOccurred())
+			for _, name := range []string{"ca.json", "tls.json"} {
+				err := ioutil.WriteFile(filepath.Join(tmpDir, "busybox", "certs", name), dataJSON, 0755)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).ToNot(HaveOccurred())
+		})
+
+		Context("when using a glob pattern", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("certs/*.json", "foo", false, nil, nil),
+					},
+				}
+			})
+
+			It("creates a secret per matching file", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-ca", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secret.StringData).To(HaveKeyWithValue("hello", "world"))
+				_, err = clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-tls", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the file names need to be sanitized", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(tmpDir, "busybox", "certs", "Root_CA.json"), dataJSON, 0755)
+				Expect(err).NotTo(HaveOccurred())
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("certs/*.json", "foo", false, nil, nil),
+					},
+				}
+			})
+
+			It("uses a valid secret name", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				_, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-root-ca", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when matching files have the same name without extension", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(tmpDir, "busybox", "certs", "ca.pem"), dataJSON, 0755)
+				Expect(err).NotTo(HaveOccurred())
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("certs/", "foo", false, nil, nil),
+					},
+				}
+			})
+
+			It("returns an error", func() {
+				err := po.Persist(context.Background())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("output files '%s' and '%s' would both be persisted to secret 'foo-ca'",
+					filepath.Join(tmpDir, "busybox", "certs", "ca.json"), filepath.Join(tmpDir, "busybox", "certs", "ca.pem")))
+			})
+		})
+
+		Context("when mapping a directory", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("certs/", "bar", false, nil, nil),
+					},
+				}
+			})
+
+			It("creates a secret per file in the directory", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				_, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "bar-ca", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "bar-tls", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when a required nested file is written later", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("keys/nested/key.json", "foo-key", false, nil, nil),
+					},
+				}
+
+				go func() {
+					defer GinkgoRecover()
+					time.Sleep(100 * time.Millisecond)
+					dir := filepath.Join(tmpDir, "busybox", "keys", "nested")
+					Expect(os.MkdirAll(dir, 0755)).ToNot(HaveOccurred())
+					Expect(ioutil.WriteFile(filepath.Join(dir, "key.json"), dataJSON, 0755)).ToNot(HaveOccurred())
+				}()
+			})
+
+			It("waits for the file in the new directories", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				_, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-key", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when a pattern matches no files", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.NewFileToSecret("keys/*.json", "foo", false, nil, nil),
+					},
+				}
+			})
+
+			It("returns an error", func() {
+				err := po.Persist(context.Background())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("no output files match 'keys/*.json'"))
+			})
+
+			Context("when the pattern is optional", func() {
+				BeforeEach(func() {
+					options := qJob.Spec.Output.OutputMap["busybox"]["keys/*.json"]
+					options.Optional = true
+					qJob.Spec.Output.OutputMap["busybox"]["keys/*.json"] = options
+				})
+
+				It("skips the pattern", func() {
+					Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when an optional file is missing", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output = &qjv1a1.Output{
+					OutputMap: qjv1a1.OutputMap{
+						"busybox": qjv1a1.FilesToSecrets{
+							"certs/ca.json": qjv1a1.SecretOptions{Name: "foo-ca"},
+							"missing.json":  qjv1a1.SecretOptions{Name: "foo-missing", Optional: true},
+						},
+					},
+				}
+			})
+
+			It("persists the other files without waiting", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				_, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-ca", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-missing", metav1.GetOptions{})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when persisting container logs", func() {
+		BeforeEach(func() {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{
+					Name: "busybox",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 0},
+					},
+				},
+			}
+			qJob.Spec.Output = &qjv1a1.Output{
+				SecretLabels: map[string]string{"key": "value"},
+				LogMap: qjv1a1.LogMap{
+					"busybox": qjv1a1.LogOptions{Name: "foo-log"},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).ToNot(HaveOccurred())
+		})
+
+		It("creates a secret with the container log", func() {
+			Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+			secret, err := clientSet.CoreV1().Secrets(namespace).Get(context.Background(), "foo-log", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.StringData).To(HaveKeyWithValue(qjv1a1.LogKey, "fake logs"))
+			Expect(secret.Labels).To(HaveKeyWithValue("key", "value"))
+			Expect(secret.Labels).To(HaveKeyWithValue(qjv1a1.LabelPersistentSecretContainer, "busybox"))
+		})
+
+		Context("when the target is a config map", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output.LogMap["busybox"] = qjv1a1.LogOptions{Name: "foo-log", Target: qjv1a1.TargetConfigMap}
+			})
+
+			It("creates a config map with the container log", func() {
+				Expect(po.Persist(context.Background())).NotTo(HaveOccurred())
+
+				configMap, err := clientSet.CoreV1().ConfigMaps(namespace).Get(context.Background(), "foo-log", metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configMap.Data).To(HaveKeyWithValue(qjv1a1.LogKey, "fake logs"))
+			})
+		})
+
+		Context("when the last line should be parsed as json", func() {
+			BeforeEach(func() {
+				qJob.Spec.Output.LogMap["busybox"] = qjv1a1.LogOptions{Name: "foo-log", ParseJSON: true}
+			})
+
+			It("fails if the log is not json", func() {
+				err := po.Persist(context.Background())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("failed to convert last log line"))
+			})
+		})
+	})
 })
@@ -0,0 +1,105 @@
+package quarksjob
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// retry queues the next attempt for a failed job, if the QuarksJob's retry
+// policy allows it. The errand reconciler starts it, once the concurrency
+// limits permit. It returns the time to wait until the next attempt is due,
+// zero if there is nothing left to do.
+func (r *ReconcileJob) retry(ctx context.Context, qj *qjv1a1.QuarksJob, job *batchv1.Job) (time.Duration, error) {
+	policy := qj.Spec.Retry
+	if policy == nil || job.Annotations[qjv1a1.AnnotationRetried] == "true" {
+		return 0, nil
+	}
+
+	attempt := jobAttempt(job)
+	if attempt >= policy.MaxAttempts {
+		ctxlog.WithEvent(qj, "RetriesExhausted").Infof(ctx, "Not retrying failed job '%s/%s', reached maximum of %d attempts", job.Namespace, job.Name, policy.MaxAttempts)
+		return 0, nil
+	}
+
+	pods, err := r.jobPods(ctx, job.Name, job.Namespace)
+	if err != nil {
+		ctxlog.Debugf(ctx, "Cannot check exit codes of failed job '%s/%s': %s", job.Namespace, job.Name, err)
+	}
+	exitCodes, outputPersistenceFailed := podExitCodes(pods)
+	if !policy.Retryable(exitCodes, outputPersistenceFailed) {
+		ctxlog.Infof(ctx, "Not retrying failed job '%s/%s', exit codes %v don't match the retry policy", job.Namespace, job.Name, exitCodes)
+		return 0, nil
+	}
+
+	if wait := time.Until(jobFinishedAt(job).Add(policy.Delay(attempt))); wait > 0 {
+		ctxlog.Debugf(ctx, "Retrying failed job '%s/%s' in %s", job.Namespace, job.Name, wait)
+		return wait, nil
+	}
+
+	if qj.Status.PendingRun != "" {
+		ctxlog.Infof(ctx, "Not retrying failed job '%s/%s', a '%s' run of quarks job '%s' is already pending", job.Namespace, job.Name, qj.Status.PendingRun, qj.GetNamespacedName())
+		return 0, nil
+	}
+
+	// Mark the job first, so a conflict prevents queuing the next attempt twice
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[qjv1a1.AnnotationRetried] = "true"
+	if err := r.client.Update(ctx, job); err != nil {
+		return 0, errors.Wrapf(err, "failed to mark job '%s/%s' as retried", job.Namespace, job.Name)
+	}
+
+	ctxlog.WithEvent(qj, "RetryingJob").Infof(ctx, "Retrying failed job '%s/%s', attempt %d of %d", job.Namespace, job.Name, attempt+1, policy.MaxAttempts)
+	qj.Status.PendingRun = qjv1a1.TriggerReasonRetry
+	qj.Status.PendingAttempt = attempt + 1
+	if err := r.client.Status().Update(ctx, qj); err != nil {
+		delete(job.Annotations, qjv1a1.AnnotationRetried)
+		if uerr := r.client.Update(ctx, job); uerr != nil {
+			ctxlog.Errorf(ctx, "Failed to unmark job '%s/%s' as retried: %s", job.Namespace, job.Name, uerr)
+		}
+		return 0, errors.Wrapf(err, "failed to queue retry of job '%s/%s'", job.Namespace, job.Name)
+	}
+	return 0, nil
+}
+
+// jobAttempt returns the attempt number of the job, jobs without annotation
+// are the first attempt
+func jobAttempt(job *batchv1.Job) int32 {
+	attempt, err := strconv.Atoi(job.Annotations[qjv1a1.AnnotationAttempt])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return int32(attempt)
+}
+
+// podExitCodes returns the non-zero exit codes of the pods' containers and
+// if the output-persist container failed
+func podExitCodes(pods []corev1.Pod) ([]int32, bool) {
+	exitCodes := []int32{}
+	outputPersistenceFailed := false
+	for _, pod := range pods {
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			if status.Name == outputPersistContainerName {
+				outputPersistenceFailed = true
+				continue
+			}
+			exitCodes = append(exitCodes, terminated.ExitCode)
+		}
+	}
+	return exitCodes, outputPersistenceFailed
+}
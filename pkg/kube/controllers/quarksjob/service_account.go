@@ -10,12 +10,16 @@ import (
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 
 	qjv1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 	"code.cloudfoundry.org/quarks-utils/pkg/names"
 	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 )
 
 const (
 	serviceAccountSecretMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	projectedTokenSuffix          = "token"
+	// rootCAConfigMap is published to every namespace since Kubernetes 1.20
+	rootCAConfigMap = "kube-root-ca.crt"
 )
 
 func (j jobCreatorImpl) getServiceAccountName(ctx context.Context, name string) (string, error) {
@@ -31,18 +35,34 @@ func (j jobCreatorImpl) getServiceAccountName(ctx context.Context, name string)
 	return "", fmt.Errorf("failed to retrieve persist output service account from namespace label '%s'", qjv1.LabelServiceAccount)
 }
 
-func (j jobCreatorImpl) serviceAccountMount(ctx context.Context, namespace string, serviceAccountName string) (*corev1.Volume, *corev1.VolumeMount, error) {
+func (j jobCreatorImpl) serviceAccountMount(ctx context.Context, qJob *qjv1.QuarksJob, serviceAccountName string, podSpec *corev1.PodSpec) (*corev1.Volume, *corev1.VolumeMount, error) {
 	var acct corev1.ServiceAccount
-	if err := j.client.Get(ctx, crc.ObjectKey{Name: serviceAccountName, Namespace: namespace}, &acct); err != nil {
+	if err := j.client.Get(ctx, crc.ObjectKey{Name: serviceAccountName, Namespace: qJob.Namespace}, &acct); err != nil {
 		return nil, nil, errors.Wrapf(err, "could not get service account '%s'", serviceAccountName)
 	}
 
+	// Projected tokens are always issued for the pod's service account, so
+	// they are only used if the template opts in to run as the persist-output
+	// service account. Otherwise the token secret is mounted into the
+	// output-persist container and the template's identity is kept.
+	if j.opConfig.ServiceAccountToken.Projected && podSpec.ServiceAccountName == serviceAccountName {
+		volume, mount := projectedServiceAccountMount(serviceAccountName, j.opConfig.ServiceAccountToken)
+		return volume, mount, nil
+	}
+
 	if len(acct.Secrets) == 0 {
+		if j.opConfig.ServiceAccountToken.Projected {
+			return nil, nil, fmt.Errorf("missing service account secret for '%s', set the template's serviceAccountName to '%s' to persist output with a projected token", serviceAccountName, serviceAccountName)
+		}
 		return nil, nil, fmt.Errorf("missing service account secret for '%s'", serviceAccountName)
 	}
-	tokenSecretName := acct.Secrets[0].Name
+	volume, mount := secretServiceAccountMount(serviceAccountName, acct.Secrets[0].Name)
+	return volume, mount, nil
+}
 
-	// Mount service account token on container
+// secretServiceAccountMount mounts the legacy token secret of the service
+// account
+func secretServiceAccountMount(serviceAccountName string, tokenSecretName string) (*corev1.Volume, *corev1.VolumeMount) {
 	serviceAccountVolumeName := names.Sanitize(fmt.Sprintf("%s-%s", serviceAccountName, tokenSecretName))
 	serviceAccountVolume := corev1.Volume{
 		Name: serviceAccountVolumeName,
@@ -59,5 +79,52 @@ func (j jobCreatorImpl) serviceAccountMount(ctx context.Context, namespace strin
 		MountPath: serviceAccountSecretMountPath,
 	}
 
-	return &serviceAccountVolume, &serviceAccountVolumeMount, nil
+	return &serviceAccountVolume, &serviceAccountVolumeMount
+}
+
+// projectedServiceAccountMount mounts a projected token, CA and namespace
+// like the kubelet does for the pod's service account
+func projectedServiceAccountMount(serviceAccountName string, token operatorconfig.ServiceAccountToken) (*corev1.Volume, *corev1.VolumeMount) {
+	expiration := token.Expiration
+	serviceAccountVolumeName := names.Sanitize(fmt.Sprintf("%s-%s", serviceAccountName, projectedTokenSuffix))
+	serviceAccountVolume := corev1.Volume{
+		Name: serviceAccountVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				DefaultMode: pointers.Int32(0644),
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          token.Audience,
+							ExpirationSeconds: &expiration,
+							Path:              "token",
+						},
+					},
+					{
+						ConfigMap: &corev1.ConfigMapProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: rootCAConfigMap},
+							Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+						},
+					},
+					{
+						DownwardAPI: &corev1.DownwardAPIProjection{
+							Items: []corev1.DownwardAPIVolumeFile{
+								{
+									Path:     "namespace",
+									FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	serviceAccountVolumeMount := corev1.VolumeMount{
+		Name:      serviceAccountVolumeName,
+		ReadOnly:  true,
+		MountPath: serviceAccountSecretMountPath,
+	}
+
+	return &serviceAccountVolume, &serviceAccountVolumeMount
 }
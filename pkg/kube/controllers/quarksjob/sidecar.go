@@ -0,0 +1,70 @@
+package quarksjob
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+)
+
+const outputPersistContainerName = "output-persist"
+
+// newOutputPersistContainer returns the output-persist container, using the
+// operator defaults unless the QuarksJob overrides them
+func newOutputPersistContainer(namespace string, output *qjv1a1.Output, defaults operatorconfig.Sidecar) corev1.Container {
+	container := corev1.Container{
+		Name:            outputPersistContainerName,
+		Image:           config.GetOperatorDockerImage(),
+		ImagePullPolicy: config.GetOperatorImagePullPolicy(),
+		Args:            []string{"persist-output"},
+		Env: []corev1.EnvVar{
+			{
+				Name:  EnvNamespace,
+				Value: namespace,
+			},
+		},
+		Resources:       *defaults.Resources.DeepCopy(),
+		SecurityContext: defaults.SecurityContext.DeepCopy(),
+	}
+
+	if output == nil || output.Sidecar == nil {
+		return container
+	}
+	sidecar := output.Sidecar
+
+	if sidecar.Image != "" {
+		container.Image = sidecar.Image
+	}
+	if sidecar.ImagePullPolicy != "" {
+		container.ImagePullPolicy = sidecar.ImagePullPolicy
+	}
+	if sidecar.Resources != nil {
+		container.Resources = *sidecar.Resources.DeepCopy()
+	}
+	if sidecar.SecurityContext != nil {
+		container.SecurityContext = sidecar.SecurityContext.DeepCopy()
+	}
+	for _, env := range sidecar.Env {
+		if env.Name == EnvNamespace {
+			continue
+		}
+		container.Env = append(container.Env, *env.DeepCopy())
+	}
+
+	return container
+}
+
+// defaultSeccompProfile sets the runtime's default seccomp profile for the
+// pod, unless the template chose one, as required by the restricted Pod
+// Security Standard
+func defaultSeccompProfile(podSpec *corev1.PodSpec) {
+	if podSpec.SecurityContext == nil {
+		podSpec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if podSpec.SecurityContext.SeccompProfile == nil {
+		podSpec.SecurityContext.SeccompProfile = &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package quarksjob
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/reference"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// WatchSyncTimeout is the time to wait for the informer of a kind listed in
+// trigger.watch entries to sync, before giving up on watching it
+const WatchSyncTimeout = 30 * time.Second
+
+// triggerWatches starts a watch for every kind of resource, which is listed
+// in the trigger.watch entries of a QuarksJob. Watches are started on
+// demand and kept until the operator stops.
+type triggerWatches struct {
+	ctx        context.Context
+	controller controller.Controller
+	client     client.Client
+	mapper     meta.RESTMapper
+	informers  cache.Informers
+	predicates []predicate.Predicate
+
+	mu      sync.Mutex
+	watched map[schema.GroupVersionKind]bool
+}
+
+func newTriggerWatches(ctx context.Context, c controller.Controller, client client.Client, mapper meta.RESTMapper, informers cache.Informers, predicates ...predicate.Predicate) *triggerWatches {
+	return &triggerWatches{
+		ctx:        ctx,
+		controller: c,
+		client:     client,
+		mapper:     mapper,
+		informers:  informers,
+		predicates: predicates,
+		watched:    map[schema.GroupVersionKind]bool{},
+	}
+}
+
+// handler returns an event handler for QuarksJobs, which starts the watches
+// for their trigger.watch entries
+func (t *triggerWatches) handler() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, _ workqueue.RateLimitingInterface) {
+			t.ensure(e.Object.(*qjv1a1.QuarksJob))
+		},
+		UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			t.ensure(e.ObjectNew.(*qjv1a1.QuarksJob))
+		},
+	}
+}
+
+// ensure starts watching the kinds of the QuarksJob's trigger.watch entries
+func (t *triggerWatches) ensure(qJob *qjv1a1.QuarksJob) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, w := range qJob.Spec.Trigger.Watch {
+		gvk := w.GroupVersionKind()
+		if t.watched[gvk] {
+			continue
+		}
+		// Reserve the kind while the watch is started, it's released
+		// if starting the watch fails
+		t.watched[gvk] = true
+
+		// Starting the watch waits for the cache to sync, don't block
+		// the QuarksJob events meanwhile
+		go t.watch(qJob.DeepCopy(), gvk)
+	}
+}
+
+func (t *triggerWatches) watch(qJob *qjv1a1.QuarksJob, gvk schema.GroupVersionKind) {
+	if err := t.start(gvk); err != nil {
+		_ = ctxlog.WithEvent(qJob, "WatchError").Errorf(t.ctx, "Failed to watch '%s' for quarks job '%s': %s", gvk, qJob.GetNamespacedName(), err)
+
+		// Try again on the next event of a QuarksJob watching the kind
+		t.mu.Lock()
+		delete(t.watched, gvk)
+		t.mu.Unlock()
+		return
+	}
+	ctxlog.Infof(t.ctx, "Watching '%s' for trigger.watch entries of quarks jobs", gvk)
+}
+
+// start adds a watch for the kind to the controller. The controller waits
+// for the kind's informer to sync without a timeout, which never happens if
+// the operator isn't allowed to list the kind. So the informer is synced
+// with a timeout first. An informer, which didn't sync, keeps retrying in
+// the background, as the cache can't remove it.
+func (t *triggerWatches) start(gvk schema.GroupVersionKind) error {
+	if _, err := t.mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return errors.Wrap(err, "unknown kind")
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+
+	ctx, cancel := context.WithTimeout(t.ctx, WatchSyncTimeout)
+	defer cancel()
+	if _, err := t.informers.GetInformer(ctx, u); err != nil {
+		return errors.Wrap(err, "informer did not sync, check the operator's permissions in 'triggerWatchRules'")
+	}
+
+	return t.controller.Watch(&source.Kind{Type: u}, handler.Funcs{UpdateFunc: t.enqueue}, t.predicates...)
+}
+
+// enqueue adds the auto-errands, whose watched fields changed, to the
+// queue. Creating and deleting watched resources doesn't trigger a run.
+func (t *triggerWatches) enqueue(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	o, ok := e.ObjectOld.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	n, ok := e.ObjectNew.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	reconciles, err := reference.GetWatchReconciles(t.ctx, t.client, o, n)
+	if err != nil {
+		ctxlog.Errorf(t.ctx, "Failed to calculate reconciles for watched %s '%s/%s': %v", n.GetKind(), n.GetNamespace(), n.GetName(), err)
+	}
+
+	for _, reconciliation := range reconciles {
+		ctxlog.NewMappingEvent(n).Debug(t.ctx, reconciliation, "QuarksJob", n.GetName(), n.GetKind())
+		q.Add(reconciliation)
+	}
+}
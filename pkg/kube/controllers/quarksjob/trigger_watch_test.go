@@ -0,0 +1,251 @@
+package quarksjob
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	qjv1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers/fakes"
+)
+
+type fakeController struct {
+	controller.Controller
+
+	mu      sync.Mutex
+	watched []string
+	err     error
+}
+
+func (c *fakeController) Watch(src source.Source, _ handler.EventHandler, _ ...predicate.Predicate) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watched = append(c.watched, src.(*source.Kind).Type.GetObjectKind().GroupVersionKind().Kind)
+	return c.err
+}
+
+func (c *fakeController) Watched() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.watched...)
+}
+
+type fakeInformers struct {
+	cache.Informers
+
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (i *fakeInformers) GetInformer(_ context.Context, _ crc.Object) (cache.Informer, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.calls++
+	return nil, i.err
+}
+
+func (i *fakeInformers) Calls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls
+}
+
+var _ = Describe("triggerWatches", func() {
+	var (
+		client    *fakes.FakeClient
+		c         *fakeController
+		mapper    *meta.DefaultRESTMapper
+		informers *fakeInformers
+		watches   *triggerWatches
+		qJobs     []qjv1.QuarksJob
+		queue     workqueue.RateLimitingInterface
+		old, new  *unstructured.Unstructured
+	)
+
+	deployment := func(image string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "web", "image": image},
+						},
+					},
+				},
+			},
+		}}
+		u.SetAPIVersion("apps/v1")
+		u.SetKind("Deployment")
+		u.SetName("web")
+		u.SetNamespace("default")
+		u.SetLabels(map[string]string{"app": "web"})
+		return u
+	}
+
+	qJob := func(name string, strategy qjv1.Strategy, w qjv1.WatchTrigger) qjv1.QuarksJob {
+		return qjv1.QuarksJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: qjv1.QuarksJobSpec{
+				Trigger: qjv1.Trigger{Strategy: strategy, Watch: []qjv1.WatchTrigger{w}},
+			},
+		}
+	}
+
+	queued := func() []string {
+		names := []string{}
+		for queue.Len() > 0 {
+			item, _ := queue.Get()
+			names = append(names, item.(reconcile.Request).Name)
+			queue.Done(item)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		client = &fakes.FakeClient{}
+		client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+			list := qjv1.QuarksJobList{Items: qJobs}
+			list.DeepCopyInto(object.(*qjv1.QuarksJobList))
+			return nil
+		})
+		c = &fakeController{}
+		mapper = meta.NewDefaultRESTMapper(nil)
+		mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+		mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Endpoints"}, meta.RESTScopeNamespace)
+		informers = &fakeInformers{}
+		watches = newTriggerWatches(context.Background(), c, client, mapper, informers)
+		queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		qJobs = nil
+		old = deployment("web:1")
+		new = deployment("web:2")
+	})
+
+	Describe("ensure", func() {
+		It("starts one watch per kind", func() {
+			deploymentWatch := qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment"}
+			q := qJob("foo", qjv1.TriggerDone, deploymentWatch)
+			q.Spec.Trigger.Watch = append(q.Spec.Trigger.Watch, qjv1.WatchTrigger{APIVersion: "v1", Kind: "Endpoints", Name: "web"})
+
+			watches.ensure(&q)
+			Eventually(c.Watched).Should(ConsistOf("Deployment", "Endpoints"))
+
+			other := qJob("bar", qjv1.TriggerDone, deploymentWatch)
+			watches.ensure(&other)
+			Consistently(c.Watched).Should(HaveLen(2))
+		})
+
+		It("doesn't watch kinds, which are unknown to the API server", func() {
+			q := qJob("foo", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "example.com/v1", Kind: "Missing"})
+
+			watches.ensure(&q)
+			Consistently(c.Watched).Should(BeEmpty())
+			Expect(informers.Calls()).To(Equal(0))
+		})
+
+		It("tries again if the informer didn't sync", func() {
+			informers.err = fmt.Errorf("failed waiting for Informer to sync")
+			q := qJob("foo", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment"})
+
+			watches.ensure(&q)
+			Eventually(informers.Calls).Should(Equal(1))
+			Eventually(func() int {
+				watches.ensure(&q)
+				return informers.Calls()
+			}).Should(BeNumerically(">=", 2))
+			Expect(c.Watched()).To(BeEmpty())
+		})
+
+		It("tries again if the watch could not be started", func() {
+			c.err = fmt.Errorf("fake-error")
+			q := qJob("foo", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment"})
+
+			watches.ensure(&q)
+			Eventually(c.Watched).Should(HaveLen(1))
+			Eventually(func() []string {
+				watches.ensure(&q)
+				return c.Watched()
+			}).Should(HaveLen(2))
+		})
+	})
+
+	Describe("enqueue", func() {
+		act := func() {
+			watches.enqueue(event.UpdateEvent{ObjectOld: old, ObjectNew: new}, queue)
+		}
+
+		It("enqueues auto-errands watching the resource by name", func() {
+			qJobs = []qjv1.QuarksJob{
+				qJob("by-name", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}),
+				qJob("other-name", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"}),
+				qJob("other-kind", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "web"}),
+				qJob("manual", qjv1.TriggerManual, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}),
+			}
+
+			act()
+			Expect(queued()).To(ConsistOf("by-name"))
+		})
+
+		It("enqueues auto-errands watching the resource by label selector", func() {
+			qJobs = []qjv1.QuarksJob{
+				qJob("matching", qjv1.TriggerDone, qjv1.WatchTrigger{
+					APIVersion: "apps/v1", Kind: "Deployment",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}),
+				qJob("not-matching", qjv1.TriggerDone, qjv1.WatchTrigger{
+					APIVersion: "apps/v1", Kind: "Deployment",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+				}),
+			}
+
+			act()
+			Expect(queued()).To(ConsistOf("matching"))
+		})
+
+		It("ignores changes to metadata and status", func() {
+			qJobs = []qjv1.QuarksJob{
+				qJob("foo", qjv1.TriggerDone, qjv1.WatchTrigger{APIVersion: "apps/v1", Kind: "Deployment"}),
+			}
+			new = old.DeepCopy()
+			new.SetResourceVersion("2")
+			new.Object["status"] = map[string]interface{}{"readyReplicas": int64(1)}
+
+			act()
+			Expect(queued()).To(BeEmpty())
+		})
+
+		It("only compares the fields selected by the JSONPath", func() {
+			qJobs = []qjv1.QuarksJob{
+				qJob("image", qjv1.TriggerDone, qjv1.WatchTrigger{
+					APIVersion: "apps/v1", Kind: "Deployment",
+					JSONPath: "{.spec.template.spec.containers[*].image}",
+				}),
+				qJob("replicas", qjv1.TriggerDone, qjv1.WatchTrigger{
+					APIVersion: "apps/v1", Kind: "Deployment",
+					JSONPath: ".spec.replicas",
+				}),
+			}
+
+			act()
+			Expect(queued()).To(ConsistOf("image"))
+		})
+	})
+})
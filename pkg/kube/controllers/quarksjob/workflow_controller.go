@@ -0,0 +1,97 @@
+package quarksjob
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// AddWorkflow creates a new QuarksWorkflow controller, which creates a
+// QuarksJob for every step of the workflow, once the step's dependencies
+// succeeded.
+func AddWorkflow(ctx context.Context, config *config.Config, _ *operatorconfig.Config, mgr manager.Manager) error {
+	f := controllerutil.SetControllerReference
+	ctx = ctxlog.NewContextWithRecorder(ctx, "workflow-reconciler", mgr.GetEventRecorderFor("workflow-recorder"))
+	r := NewWorkflowReconciler(ctx, config, mgr, f)
+	c, err := controller.New("workflow-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: config.MaxQuarksJobWorkers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Adding Workflow controller to manager failed.")
+	}
+
+	nsPredicate := newNSPredicate(ctx, mgr.GetClient(), config.MonitoredID)
+
+	// Trigger when a workflow is created or its spec changed
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return true },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration()
+		},
+	}
+	err = c.Watch(&source.Kind{Type: &qjv1a1.QuarksWorkflow{}}, &handler.EnqueueRequestForObject{}, nsPredicate, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching quarks workflows failed in Workflow controller.")
+	}
+
+	// Trigger when the run of a step's quarks job finished or was cancelled
+	p = predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*qjv1a1.QuarksJob)
+			n := e.ObjectNew.(*qjv1a1.QuarksJob)
+			if _, ok := n.Labels[qjv1a1.LabelWorkflowName]; !ok {
+				return false
+			}
+
+			shouldProcessEvent := conditionChanged(o, n, qjv1a1.ConditionSucceeded) || conditionChanged(o, n, qjv1a1.ConditionCancelled)
+			if shouldProcessEvent {
+				ctxlog.NewPredicateEvent(n).Debug(
+					ctx, e.ObjectNew, qjv1a1.QuarksJobResourceName,
+					fmt.Sprintf("Update predicate passed for '%s/%s', the run of a workflow step changed its state",
+						e.ObjectNew.GetNamespace(),
+						e.ObjectNew.GetName()),
+				)
+			}
+
+			return shouldProcessEvent
+		},
+	}
+	err = c.Watch(&source.Kind{Type: &qjv1a1.QuarksJob{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &qjv1a1.QuarksWorkflow{},
+		IsController: true,
+	}, nsPredicate, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching quarks jobs failed in Workflow controller.")
+	}
+
+	return nil
+}
+
+func conditionChanged(o, n *qjv1a1.QuarksJob, conditionType string) bool {
+	oc := meta.FindStatusCondition(o.Status.Conditions, conditionType)
+	nc := meta.FindStatusCondition(n.Status.Conditions, conditionType)
+	if oc == nil || nc == nil {
+		return oc != nc
+	}
+	return oc.Status != nc.Status
+}
@@ -0,0 +1,288 @@
+package quarksjob
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/names"
+)
+
+// NewWorkflowReconciler returns a new reconciler for QuarksWorkflows
+func NewWorkflowReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, f setOwnerReferenceFunc) reconcile.Reconciler {
+	return &WorkflowReconciler{
+		ctx:          ctx,
+		config:       config,
+		client:       mgr.GetClient(),
+		scheme:       mgr.GetScheme(),
+		setReference: f,
+	}
+}
+
+// WorkflowReconciler creates a QuarksJob for every step of a QuarksWorkflow,
+// once the step's dependencies succeeded
+type WorkflowReconciler struct {
+	ctx          context.Context
+	client       crc.Client
+	scheme       *runtime.Scheme
+	setReference setOwnerReferenceFunc
+	config       *config.Config
+}
+
+// Reconcile starts the workflow's steps and updates their status
+func (r *WorkflowReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
+	wf := &qjv1a1.QuarksWorkflow{}
+
+	// Set the ctx to be Background, as the top-level context for incoming requests.
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+
+	ctxlog.Infof(ctx, "Reconciling quarks workflow '%s'", request.NamespacedName)
+	if err := r.client.Get(ctx, request.NamespacedName, wf); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Do not requeue, quarks workflow is probably deleted.
+			ctxlog.Infof(ctx, "Failed to find quarks workflow '%s', not retrying: %s", request.NamespacedName, err)
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		ctxlog.Errorf(ctx, "Failed to get quarks workflow '%s': %s", request.NamespacedName, err)
+		return reconcile.Result{}, err
+	}
+
+	status := wf.Status.DeepCopy()
+	if status.StartedAt == nil {
+		now := metav1.Now()
+		status.StartedAt = &now
+	}
+
+	steps, err := wf.Validate()
+	if err != nil {
+		_ = ctxlog.WithEvent(wf, "InvalidWorkflow").Errorf(ctx, "Invalid quarks workflow '%s': %s", wf.GetNamespacedName(), err)
+		status.Phase = qjv1a1.WorkflowFailed
+		status.Message = err.Error()
+		return reconcile.Result{}, r.updateStatus(ctx, wf, status)
+	}
+
+	qJobs, err := r.stepQuarksJobs(ctx, wf)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	failed := ""
+	for _, step := range steps {
+		if qJob, ok := qJobs[step.Name]; ok && stepPhase(qJob) == qjv1a1.WorkflowFailed {
+			failed = step.Name
+			break
+		}
+	}
+
+	phases := map[string]qjv1a1.WorkflowPhase{}
+	status.Steps = []qjv1a1.WorkflowStepStatus{}
+	for _, step := range steps {
+		stepStatus := qjv1a1.WorkflowStepStatus{Name: step.Name, QuarksJob: wf.QuarksJobName(step.Name)}
+
+		if qJob, ok := qJobs[step.Name]; ok {
+			stepStatus.Phase = stepPhase(qJob)
+			if failed != "" && wf.Spec.FailFast && stepStatus.Phase == qjv1a1.WorkflowRunning {
+				stepStatus.Message = fmt.Sprintf("Cancelling, step '%s' failed", failed)
+				if err := r.cancelStep(ctx, wf, qJob); err != nil {
+					return reconcile.Result{}, err
+				}
+			}
+		} else {
+			stepStatus.QuarksJob = ""
+			stepStatus.Phase, stepStatus.Message = pendingStepPhase(step, phases)
+			if failed != "" && wf.Spec.FailFast {
+				stepStatus.Phase = qjv1a1.WorkflowSkipped
+				stepStatus.Message = fmt.Sprintf("Step '%s' failed", failed)
+			}
+			if stepStatus.Phase == qjv1a1.WorkflowRunning {
+				if err := r.startStep(ctx, wf, step); err != nil {
+					return reconcile.Result{}, err
+				}
+				stepStatus.QuarksJob = wf.QuarksJobName(step.Name)
+			}
+		}
+
+		phases[step.Name] = stepStatus.Phase
+		status.Steps = append(status.Steps, stepStatus)
+	}
+
+	status.Phase, status.Message = workflowPhase(status.Steps)
+	if status.Phase.Finished() && status.FinishedAt == nil {
+		now := metav1.Now()
+		status.FinishedAt = &now
+		ctxlog.WithEvent(wf, "Finished").Infof(ctx, "Quarks workflow '%s' finished: %s", wf.GetNamespacedName(), status.Phase)
+	}
+
+	return reconcile.Result{}, r.updateStatus(ctx, wf, status)
+}
+
+// stepQuarksJobs returns the QuarksJobs created for the workflow's steps,
+// by step name
+func (r *WorkflowReconciler) stepQuarksJobs(ctx context.Context, wf *qjv1a1.QuarksWorkflow) (map[string]*qjv1a1.QuarksJob, error) {
+	list := &qjv1a1.QuarksJobList{}
+	err := r.client.List(ctx, list,
+		crc.InNamespace(wf.Namespace),
+		crc.MatchingLabels{qjv1a1.LabelWorkflowName: wf.Name},
+	)
+	if err != nil {
+		return nil, ctxlog.WithEvent(wf, "ListError").Errorf(ctx, "Failed to list quarks jobs of workflow '%s': %s", wf.GetNamespacedName(), err)
+	}
+
+	qJobs := map[string]*qjv1a1.QuarksJob{}
+	for i := range list.Items {
+		qJob := &list.Items[i]
+		if !metav1.IsControlledBy(qJob, wf) {
+			continue
+		}
+		qJobs[qJob.Labels[qjv1a1.LabelWorkflowStep]] = qJob
+	}
+	return qJobs, nil
+}
+
+// startStep creates the step's QuarksJob, which runs once
+func (r *WorkflowReconciler) startStep(ctx context.Context, wf *qjv1a1.QuarksWorkflow, step qjv1a1.WorkflowStep) error {
+	qJob := &qjv1a1.QuarksJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wf.QuarksJobName(step.Name),
+			Namespace: wf.Namespace,
+			Labels: map[string]string{
+				qjv1a1.LabelWorkflowName: wf.Name,
+				qjv1a1.LabelWorkflowStep: step.Name,
+			},
+		},
+		Spec: *step.Template.DeepCopy(),
+	}
+	qJob.Spec.Trigger.Strategy = qjv1a1.TriggerOnce
+	addWorkflowInputs(wf, step, &qJob.Spec)
+
+	if err := r.setReference(wf, qJob, r.scheme); err != nil {
+		return ctxlog.WithEvent(wf, "NewStepError").Errorf(ctx, "Failed to set reference for step '%s' of workflow '%s': %s", step.Name, wf.GetNamespacedName(), err)
+	}
+
+	err := r.client.Create(ctx, qJob)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return ctxlog.WithEvent(wf, "CreateStepError").Errorf(ctx, "Failed to create quarks job for step '%s' of workflow '%s': %s", step.Name, wf.GetNamespacedName(), err)
+	}
+
+	ctxlog.WithEvent(wf, "StartingStep").Infof(ctx, "Starting step '%s' of quarks workflow '%s'", step.Name, wf.GetNamespacedName())
+	return nil
+}
+
+// cancelStep terminates the running jobs of the step's QuarksJob
+func (r *WorkflowReconciler) cancelStep(ctx context.Context, wf *qjv1a1.QuarksWorkflow, qJob *qjv1a1.QuarksJob) error {
+	if qJob.Annotations[qjv1a1.AnnotationCancel] == "true" {
+		return nil
+	}
+
+	if qJob.Annotations == nil {
+		qJob.Annotations = map[string]string{}
+	}
+	qJob.Annotations[qjv1a1.AnnotationCancel] = "true"
+	if err := r.client.Update(ctx, qJob); err != nil {
+		return ctxlog.WithEvent(wf, "UpdateError").Errorf(ctx, "Failed to cancel quarks job '%s' of workflow '%s': %s", qJob.GetNamespacedName(), wf.GetNamespacedName(), err)
+	}
+
+	ctxlog.WithEvent(wf, "CancellingStep").Infof(ctx, "Cancelling quarks job '%s' of workflow '%s'", qJob.GetNamespacedName(), wf.GetNamespacedName())
+	return nil
+}
+
+func (r *WorkflowReconciler) updateStatus(ctx context.Context, wf *qjv1a1.QuarksWorkflow, status *qjv1a1.QuarksWorkflowStatus) error {
+	if reflect.DeepEqual(&wf.Status, status) {
+		return nil
+	}
+
+	wf.Status = *status
+	if err := r.client.Status().Update(ctx, wf); err != nil {
+		return ctxlog.WithEvent(wf, "UpdateError").Errorf(ctx, "Failed to update status of quarks workflow '%s': %s", wf.GetNamespacedName(), err)
+	}
+	return nil
+}
+
+// addWorkflowInputs mounts the output secrets of the step's dependencies into
+// the step's containers. As the secrets are referenced by the template, the
+// job is only created once they exist.
+func addWorkflowInputs(wf *qjv1a1.QuarksWorkflow, step qjv1a1.WorkflowStep, spec *qjv1a1.QuarksJobSpec) {
+	podSpec := &spec.Template.Spec.Template.Spec
+	for _, dep := range step.DependsOn {
+		for _, secret := range wf.Step(dep).OutputSecrets() {
+			volumeName := names.Sanitize("input-" + secret)
+			podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+				Name: volumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: secret},
+				},
+			})
+			for i := range podSpec.Containers {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, corev1.VolumeMount{
+					Name:      volumeName,
+					MountPath: filepath.Join(qjv1a1.WorkflowInputsMountPath, dep, secret),
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+}
+
+// stepPhase returns the phase of a step from the status of its QuarksJob
+func stepPhase(qJob *qjv1a1.QuarksJob) qjv1a1.WorkflowPhase {
+	switch {
+	case meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionCancelled):
+		return qjv1a1.WorkflowCancelled
+	case meta.IsStatusConditionTrue(qJob.Status.Conditions, qjv1a1.ConditionSucceeded):
+		return qjv1a1.WorkflowSucceeded
+	case meta.IsStatusConditionFalse(qJob.Status.Conditions, qjv1a1.ConditionSucceeded):
+		return qjv1a1.WorkflowFailed
+	}
+	return qjv1a1.WorkflowRunning
+}
+
+// pendingStepPhase returns the phase of a step without QuarksJob. Running
+// means the step can be started.
+func pendingStepPhase(step qjv1a1.WorkflowStep, phases map[string]qjv1a1.WorkflowPhase) (qjv1a1.WorkflowPhase, string) {
+	waiting := false
+	for _, dep := range step.DependsOn {
+		switch phases[dep] {
+		case qjv1a1.WorkflowSucceeded:
+		case qjv1a1.WorkflowFailed, qjv1a1.WorkflowSkipped, qjv1a1.WorkflowCancelled:
+			return qjv1a1.WorkflowSkipped, fmt.Sprintf("Dependency '%s' did not succeed", dep)
+		default:
+			waiting = true
+		}
+	}
+	if waiting {
+		return qjv1a1.WorkflowPending, ""
+	}
+	return qjv1a1.WorkflowRunning, ""
+}
+
+// workflowPhase returns the phase of the workflow from the phases of its steps
+func workflowPhase(steps []qjv1a1.WorkflowStepStatus) (qjv1a1.WorkflowPhase, string) {
+	failed := ""
+	for _, step := range steps {
+		if !step.Phase.Finished() {
+			return qjv1a1.WorkflowRunning, ""
+		}
+		if failed == "" && step.Phase != qjv1a1.WorkflowSucceeded && step.Phase != qjv1a1.WorkflowSkipped {
+			failed = step.Name
+		}
+	}
+	if failed != "" {
+		return qjv1a1.WorkflowFailed, fmt.Sprintf("Step '%s' did not succeed", failed)
+	}
+	return qjv1a1.WorkflowSucceeded, ""
+}
@@ -0,0 +1,331 @@
+package quarksjob_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers"
+	cfakes "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/fakes"
+	qj "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/quarksjob"
+	"code.cloudfoundry.org/quarks-job/testing"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("WorkflowReconciler", func() {
+	var (
+		manager      *cfakes.FakeManager
+		reconciler   reconcile.Reconciler
+		request      reconcile.Request
+		log          *zap.SugaredLogger
+		logs         *observer.ObservedLogs
+		client       *cfakes.FakeClient
+		statusWriter *cfakes.FakeStatusWriter
+		env          testing.Catalog
+		wf           *qjv1a1.QuarksWorkflow
+		steps        []qjv1a1.QuarksJob
+	)
+
+	// step returns the quarks job of a step, which has the given condition
+	step := func(name string, conditions ...metav1.Condition) qjv1a1.QuarksJob {
+		return qjv1a1.QuarksJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      wf.QuarksJobName(name),
+				Namespace: wf.Namespace,
+				Labels: map[string]string{
+					qjv1a1.LabelWorkflowName: wf.Name,
+					qjv1a1.LabelWorkflowStep: name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{Name: wf.Name, UID: wf.UID, Controller: pointers.Bool(true)},
+				},
+			},
+			Status: qjv1a1.QuarksJobStatus{Conditions: conditions},
+		}
+	}
+	succeeded := metav1.Condition{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionTrue, Reason: "JobSucceeded"}
+	failed := metav1.Condition{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionFalse, Reason: "JobFailed"}
+
+	created := func() []*qjv1a1.QuarksJob {
+		qJobs := []*qjv1a1.QuarksJob{}
+		for i := 0; i < client.CreateCallCount(); i++ {
+			_, object, _ := client.CreateArgsForCall(i)
+			qJobs = append(qJobs, object.(*qjv1a1.QuarksJob))
+		}
+		return qJobs
+	}
+
+	status := func() qjv1a1.QuarksWorkflowStatus {
+		Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+		_, object, _ := statusWriter.UpdateArgsForCall(0)
+		return object.(*qjv1a1.QuarksWorkflow).Status
+	}
+
+	phases := func(status qjv1a1.QuarksWorkflowStatus) map[string]qjv1a1.WorkflowPhase {
+		phases := map[string]qjv1a1.WorkflowPhase{}
+		for _, s := range status.Steps {
+			phases[s.Name] = s.Phase
+		}
+		return phases
+	}
+
+	BeforeEach(func() {
+		err := controllers.AddToScheme(scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+		manager = &cfakes.FakeManager{}
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "deploy", Namespace: "default"}}
+		logs, log = helper.NewTestLogger()
+		wf = env.DefaultQuarksWorkflow("deploy", "default")
+		steps = nil
+
+		client = &cfakes.FakeClient{}
+		client.GetCalls(func(context context.Context, nn types.NamespacedName, object crc.Object) error {
+			switch object := object.(type) {
+			case *qjv1a1.QuarksWorkflow:
+				wf.DeepCopyInto(object)
+				return nil
+			}
+			return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+		})
+		client.ListCalls(func(context context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+			switch object := object.(type) {
+			case *qjv1a1.QuarksJobList:
+				list := qjv1a1.QuarksJobList{Items: steps}
+				list.DeepCopyInto(object)
+			}
+			return nil
+		})
+		statusWriter = &cfakes.FakeStatusWriter{}
+		client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+		manager.GetClientReturns(client)
+		manager.GetSchemeReturns(scheme.Scheme)
+	})
+
+	JustBeforeEach(func() {
+		ctx := ctxlog.NewParentContext(log)
+		config := helper.NewConfigWithTimeout(10 * time.Second)
+		reconciler = qj.NewWorkflowReconciler(ctx, config, manager, controllerutil.SetControllerReference)
+	})
+
+	act := func() (reconcile.Result, error) {
+		return reconciler.Reconcile(context.Background(), request)
+	}
+
+	Context("when the workflow is created", func() {
+		It("starts the steps without dependencies", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(created()).To(HaveLen(1))
+			qJob := created()[0]
+			Expect(qJob.Name).To(Equal("deploy-generate"))
+			Expect(qJob.Labels).To(HaveKeyWithValue(qjv1a1.LabelWorkflowStep, "generate"))
+			Expect(qJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerOnce))
+			Expect(metav1.IsControlledBy(qJob, wf)).To(BeTrue())
+			Expect(logs.FilterMessageSnippet("Starting step 'generate' of quarks workflow 'default/deploy'").Len()).To(Equal(1))
+
+			s := status()
+			Expect(s.Phase).To(Equal(qjv1a1.WorkflowRunning))
+			Expect(s.StartedAt).ToNot(BeNil())
+			Expect(s.Steps).To(HaveLen(4))
+			Expect(s.Steps[0].Name).To(Equal("generate"))
+			Expect(s.Steps[0].QuarksJob).To(Equal("deploy-generate"))
+			Expect(phases(s)).To(Equal(map[string]qjv1a1.WorkflowPhase{
+				"generate": qjv1a1.WorkflowRunning,
+				"left":     qjv1a1.WorkflowPending,
+				"right":    qjv1a1.WorkflowPending,
+				"final":    qjv1a1.WorkflowPending,
+			}))
+		})
+	})
+
+	Context("when a step succeeded", func() {
+		BeforeEach(func() {
+			steps = []qjv1a1.QuarksJob{step("generate", succeeded)}
+		})
+
+		It("fans out to the steps depending on it", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(created()).To(HaveLen(2))
+			Expect(created()[0].Name).To(Equal("deploy-left"))
+			Expect(created()[1].Name).To(Equal("deploy-right"))
+			Expect(phases(status())).To(Equal(map[string]qjv1a1.WorkflowPhase{
+				"generate": qjv1a1.WorkflowSucceeded,
+				"left":     qjv1a1.WorkflowRunning,
+				"right":    qjv1a1.WorkflowRunning,
+				"final":    qjv1a1.WorkflowPending,
+			}))
+		})
+
+		It("mounts the unversioned output secrets of the dependency", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+
+			podSpec := created()[0].Spec.Template.Spec.Template.Spec
+			Expect(podSpec.Volumes).To(ConsistOf(
+				corev1.Volume{Name: "input-foo-busybox", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "foo-busybox"}}},
+				corev1.Volume{Name: "input-fake-nats", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "fake-nats"}}},
+			))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "input-foo-busybox",
+				MountPath: "/mnt/quarks/inputs/generate/foo-busybox",
+				ReadOnly:  true,
+			}))
+		})
+	})
+
+	Context("when all steps succeeded", func() {
+		BeforeEach(func() {
+			steps = []qjv1a1.QuarksJob{
+				step("generate", succeeded),
+				step("left", succeeded),
+				step("right", succeeded),
+				step("final", succeeded),
+			}
+		})
+
+		It("marks the workflow as succeeded", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+
+			s := status()
+			Expect(s.Phase).To(Equal(qjv1a1.WorkflowSucceeded))
+			Expect(s.FinishedAt).ToNot(BeNil())
+		})
+	})
+
+	Context("when a step failed", func() {
+		BeforeEach(func() {
+			steps = []qjv1a1.QuarksJob{
+				step("generate", succeeded),
+				step("left", failed),
+				step("right"),
+			}
+		})
+
+		It("keeps running independent steps and skips the dependent steps", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.UpdateCallCount()).To(Equal(0))
+
+			s := status()
+			Expect(s.Phase).To(Equal(qjv1a1.WorkflowRunning))
+			Expect(phases(s)).To(Equal(map[string]qjv1a1.WorkflowPhase{
+				"generate": qjv1a1.WorkflowSucceeded,
+				"left":     qjv1a1.WorkflowFailed,
+				"right":    qjv1a1.WorkflowRunning,
+				"final":    qjv1a1.WorkflowSkipped,
+			}))
+			Expect(s.Steps[3].Message).To(Equal("Dependency 'left' did not succeed"))
+		})
+
+		It("fails the workflow once the other steps finished", func() {
+			steps[2] = step("right", succeeded)
+
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+
+			s := status()
+			Expect(s.Phase).To(Equal(qjv1a1.WorkflowFailed))
+			Expect(s.Message).To(Equal("Step 'left' did not succeed"))
+			Expect(s.FinishedAt).ToNot(BeNil())
+		})
+
+		Context("when the workflow fails fast", func() {
+			BeforeEach(func() {
+				wf.Spec.FailFast = true
+			})
+
+			It("cancels the running steps", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(client.UpdateCallCount()).To(Equal(1))
+				_, object, _ := client.UpdateArgsForCall(0)
+				Expect(object.GetName()).To(Equal("deploy-right"))
+				Expect(object.GetAnnotations()).To(HaveKeyWithValue(qjv1a1.AnnotationCancel, "true"))
+
+				s := status()
+				Expect(s.Phase).To(Equal(qjv1a1.WorkflowRunning))
+				Expect(s.Steps[2].Message).To(Equal("Cancelling, step 'left' failed"))
+			})
+
+			It("skips the pending steps", func() {
+				steps = []qjv1a1.QuarksJob{step("generate", failed)}
+
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(0))
+
+				s := status()
+				Expect(s.Phase).To(Equal(qjv1a1.WorkflowFailed))
+				Expect(phases(s)).To(Equal(map[string]qjv1a1.WorkflowPhase{
+					"generate": qjv1a1.WorkflowFailed,
+					"left":     qjv1a1.WorkflowSkipped,
+					"right":    qjv1a1.WorkflowSkipped,
+					"final":    qjv1a1.WorkflowSkipped,
+				}))
+			})
+		})
+	})
+
+	Context("when the steps have a dependency cycle", func() {
+		BeforeEach(func() {
+			wf.Spec.Steps[1].DependsOn = []string{"final"}
+		})
+
+		It("fails the workflow without starting steps", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+
+			s := status()
+			Expect(s.Phase).To(Equal(qjv1a1.WorkflowFailed))
+			Expect(s.Message).To(Equal("workflow steps have a dependency cycle"))
+		})
+	})
+
+	Context("when the status did not change", func() {
+		BeforeEach(func() {
+			now := metav1.Now()
+			wf.Status = qjv1a1.QuarksWorkflowStatus{
+				Phase:     qjv1a1.WorkflowRunning,
+				StartedAt: &now,
+				Steps: []qjv1a1.WorkflowStepStatus{
+					{Name: "generate", Phase: qjv1a1.WorkflowRunning, QuarksJob: "deploy-generate"},
+					{Name: "left", Phase: qjv1a1.WorkflowPending},
+					{Name: "right", Phase: qjv1a1.WorkflowPending},
+					{Name: "final", Phase: qjv1a1.WorkflowPending},
+				},
+			}
+			steps = []qjv1a1.QuarksJob{step("generate")}
+		})
+
+		It("does not update the status", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -7,6 +7,8 @@ import (
 
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	extv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
@@ -16,10 +18,16 @@ import (
 
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	"code.cloudfoundry.org/quarks-job/pkg/kube/controllers"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/triggerserver"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/util/operatorconfig"
 )
 
+// minTokenProjectionVersion is the first Kubernetes version with stable
+// service account token volume projection and the root CA config map
+var minTokenProjectionVersion = version.MustParseGeneric("1.20.0")
+
 // NewManager adds schemes, controllers and starts the manager
-func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, options manager.Options) (manager.Manager, error) {
+func NewManager(ctx context.Context, config *config.Config, opConfig *operatorconfig.Config, cfg *rest.Config, options manager.Options) (manager.Manager, error) {
 	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize new manager")
@@ -35,43 +43,128 @@ func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, op
 		return nil, errors.Wrap(err, "failed to add manager scheme to controllers")
 	}
 
+	err = detectServiceAccountTokenProjection(ctx, opConfig, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect service account token projection")
+	}
+
 	// Setup all Controllers
-	err = controllers.AddToManager(ctx, config, mgr)
+	err = controllers.AddToManager(ctx, config, opConfig, mgr)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add controllers to manager")
 	}
 
+	if ts := opConfig.TriggerServer; ts.Address != "" {
+		serverCtx := ctxlog.NewContextWithRecorder(ctx, "trigger-server", mgr.GetEventRecorderFor("trigger-recorder"))
+		err = mgr.Add(triggerserver.New(serverCtx, mgr.GetClient(), ts.Address, ts.CertFile, ts.KeyFile, ts.Audience))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to add trigger server to manager")
+		}
+	}
+
 	return mgr, nil
 }
 
-// ApplyCRDs applies a collection of CRDs into the cluster
-func ApplyCRDs(ctx context.Context, config *rest.Config) error {
-	client, err := extv1client.NewForConfig(config)
+// detectServiceAccountTokenProjection enables projected service account
+// tokens for the persist-output container, if the cluster supports them.
+// Older clusters fall back to the legacy service account token secrets.
+func detectServiceAccountTokenProjection(ctx context.Context, opConfig *operatorconfig.Config, cfg *rest.Config) error {
+	client, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
-		return errors.Wrap(err, "Could not get kube client")
+		return errors.Wrap(err, "could not get discovery client")
 	}
 
-	b := crd.New(
-		qjv1a1.QuarksJobResourceName,
-		extv1.CustomResourceDefinitionNames{
-			Kind:       qjv1a1.QuarksJobResourceKind,
-			Plural:     qjv1a1.QuarksJobResourcePlural,
-			ShortNames: qjv1a1.QuarksJobResourceShortNames,
-		},
-		qjv1a1.SchemeGroupVersion,
-	)
+	info, err := client.ServerVersion()
+	if err != nil {
+		return errors.Wrap(err, "could not get server version")
+	}
 
-	err = b.WithValidation(&qjv1a1.QuarksJobValidation).
-		WithAdditionalPrinterColumns(qjv1a1.QuarksJobAdditionalPrinterColumns).
-		Build().
-		Apply(ctx, client)
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
 	if err != nil {
-		return errors.Wrapf(err, "failed to apply CRD '%s'", qjv1a1.QuarksJobResourceName)
+		return errors.Wrapf(err, "could not parse server version '%s'", info.GitVersion)
 	}
 
-	err = crd.WaitForCRDReady(ctx, client, qjv1a1.QuarksJobResourceName)
+	projected := serverVersion.AtLeast(minTokenProjectionVersion)
+	ctxlog.Infof(ctx, "Kubernetes %s, using projected service account tokens: %t", serverVersion, projected)
+	opConfig.ServiceAccountToken.Projected = projected
+
+	return nil
+}
+
+// ApplyCRDs applies a collection of CRDs into the cluster
+func ApplyCRDs(ctx context.Context, config *rest.Config) error {
+	client, err := extv1client.NewForConfig(config)
 	if err != nil {
-		return errors.Wrapf(err, "failed to wait for CRD '%s' ready", qjv1a1.QuarksJobResourceName)
+		return errors.Wrap(err, "Could not get kube client")
+	}
+
+	for _, res := range []struct {
+		name                     string
+		names                    extv1.CustomResourceDefinitionNames
+		validation               *extv1.CustomResourceValidation
+		additionalPrinterColumns []extv1.CustomResourceColumnDefinition
+		scope                    extv1.ResourceScope
+	}{
+		{
+			qjv1a1.QuarksJobResourceName,
+			extv1.CustomResourceDefinitionNames{
+				Kind:       qjv1a1.QuarksJobResourceKind,
+				Plural:     qjv1a1.QuarksJobResourcePlural,
+				ShortNames: qjv1a1.QuarksJobResourceShortNames,
+			},
+			&qjv1a1.QuarksJobValidation,
+			qjv1a1.QuarksJobAdditionalPrinterColumns,
+			extv1.NamespaceScoped,
+		},
+		{
+			qjv1a1.QuarksJobDefaultsResourceName,
+			extv1.CustomResourceDefinitionNames{
+				Kind:       qjv1a1.QuarksJobDefaultsResourceKind,
+				Plural:     qjv1a1.QuarksJobDefaultsResourcePlural,
+				ShortNames: qjv1a1.QuarksJobDefaultsResourceShortNames,
+			},
+			&qjv1a1.QuarksJobDefaultsValidation,
+			nil,
+			extv1.NamespaceScoped,
+		},
+		{
+			qjv1a1.ClusterQuarksJobDefaultsResourceName,
+			extv1.CustomResourceDefinitionNames{
+				Kind:       qjv1a1.ClusterQuarksJobDefaultsResourceKind,
+				Plural:     qjv1a1.ClusterQuarksJobDefaultsResourcePlural,
+				ShortNames: qjv1a1.ClusterQuarksJobDefaultsResourceShortNames,
+			},
+			&qjv1a1.QuarksJobDefaultsValidation,
+			nil,
+			extv1.ClusterScoped,
+		},
+		{
+			qjv1a1.QuarksWorkflowResourceName,
+			extv1.CustomResourceDefinitionNames{
+				Kind:       qjv1a1.QuarksWorkflowResourceKind,
+				Plural:     qjv1a1.QuarksWorkflowResourcePlural,
+				ShortNames: qjv1a1.QuarksWorkflowResourceShortNames,
+			},
+			&qjv1a1.QuarksWorkflowValidation,
+			qjv1a1.QuarksWorkflowAdditionalPrinterColumns,
+			extv1.NamespaceScoped,
+		},
+	} {
+		b := crd.New(res.name, res.names, qjv1a1.SchemeGroupVersion).
+			WithValidation(res.validation).
+			WithAdditionalPrinterColumns(res.additionalPrinterColumns).
+			Build()
+		b.CRD.Spec.Scope = res.scope
+
+		err = b.Apply(ctx, client)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply CRD '%s'", res.name)
+		}
+
+		err = crd.WaitForCRDReady(ctx, client, res.name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to wait for CRD '%s' ready", res.name)
+		}
 	}
 
 	return nil
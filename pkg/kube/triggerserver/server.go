@@ -0,0 +1,364 @@
+// Package triggerserver implements the HTTP trigger endpoint, which lets
+// clients, e.g. CI systems, run QuarksJobs and poll their status, without
+// permission to modify QuarksJobs
+package triggerserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/quarks-job/pkg/kube/apis"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+const (
+	// SubresourceTrigger is the subresource of quarksjobs, which clients
+	// need the 'create' permission for, to trigger a run
+	SubresourceTrigger = "trigger"
+
+	shutdownTimeout = 10 * time.Second
+	maxBodySize     = 64 * 1024
+)
+
+var (
+	// /v1/namespaces/{namespace}/quarksjobs/{name}[/runs]
+	pathPattern = regexp.MustCompile(`^/v1/namespaces/([^/]+)/quarksjobs/([^/]+)(/runs)?$`)
+	// parameterPattern matches parameter names, which are valid in env var names
+	parameterPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// TriggerRequest is the optional body of a trigger request
+type TriggerRequest struct {
+	// Parameters are passed to the job's containers as QUARKS_PARAM_<name>
+	// environment variables
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// RunStatus is the response to trigger and status requests
+type RunStatus struct {
+	Name       string               `json:"name"`
+	Namespace  string               `json:"namespace"`
+	PendingRun qjv1a1.TriggerReason `json:"pendingRun,omitempty"`
+	WaitingFor []string             `json:"waitingFor,omitempty"`
+	Parameters map[string]string    `json:"parameters,omitempty"`
+	Conditions []metav1.Condition   `json:"conditions,omitempty"`
+	// Jobs are the jobs of the QuarksJob, newest first
+	Jobs []JobStatus `json:"jobs"`
+}
+
+// JobStatus is the status of a single job
+type JobStatus struct {
+	Name           string       `json:"name"`
+	RunID          string       `json:"runID,omitempty"`
+	TriggerReason  string       `json:"triggerReason,omitempty"`
+	Attempt        string       `json:"attempt,omitempty"`
+	Active         int32        `json:"active"`
+	Succeeded      int32        `json:"succeeded"`
+	Failed         int32        `json:"failed"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Server is the HTTP trigger endpoint. Clients authenticate with a bearer
+// token for the server's audience, which is checked by a TokenReview. A
+// SubjectAccessReview checks the client may 'create' the 'quarksjobs/trigger'
+// subresource to trigger a run or 'get' the quarksjob to poll its status.
+type Server struct {
+	ctx      context.Context
+	client   crc.Client
+	address  string
+	certFile string
+	keyFile  string
+	audience string
+}
+
+// New returns a new trigger server
+func New(ctx context.Context, client crc.Client, address, certFile, keyFile, audience string) *Server {
+	return &Server{
+		ctx:      ctx,
+		client:   client,
+		address:  address,
+		certFile: certFile,
+		keyFile:  keyFile,
+		audience: audience,
+	}
+}
+
+// NeedLeaderElection returns false, as all operator replicas can serve
+// trigger requests
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves requests until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if s.certFile != "" {
+			ctxlog.Infof(s.ctx, "Serving trigger endpoint on '%s' with TLS", s.address)
+			errs <- server.ListenAndServeTLS(s.certFile, s.keyFile)
+			return
+		}
+		ctxlog.Infof(s.ctx, "Serving trigger endpoint on '%s' without TLS", s.address)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return errors.Wrap(err, "trigger server failed")
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// Handler returns the HTTP handler of the trigger endpoint
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	match := pathPattern.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	nn := types.NamespacedName{Namespace: match[1], Name: match[2]}
+	trigger := match[3] != ""
+
+	var verb, subresource string
+	switch {
+	case trigger && r.Method == http.MethodPost:
+		verb, subresource = "create", SubresourceTrigger
+	case !trigger && r.Method == http.MethodGet:
+		verb = "get"
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx := r.Context()
+	user, err := s.authenticate(ctx, r)
+	if err != nil {
+		ctxlog.Infof(s.ctx, "Rejected trigger endpoint request for '%s': %s", nn, err)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	allowed, err := s.authorize(ctx, user, verb, subresource, nn)
+	if err != nil {
+		ctxlog.Errorf(s.ctx, "Failed to authorize trigger endpoint request for '%s': %s", nn, err)
+		writeError(w, http.StatusInternalServerError, "authorization failed")
+		return
+	}
+	if !allowed {
+		ctxlog.Infof(s.ctx, "User '%s' may not %s quarks job '%s'", user.Username, verb, nn)
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	qJob := &qjv1a1.QuarksJob{}
+	if err := s.client.Get(ctx, nn, qJob); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("quarks job '%s' not found", nn))
+			return
+		}
+		ctxlog.Errorf(s.ctx, "Failed to get quarks job '%s': %s", nn, err)
+		writeError(w, http.StatusInternalServerError, "failed to get quarks job")
+		return
+	}
+
+	status := http.StatusOK
+	if trigger {
+		req := TriggerRequest{}
+		if err := decodeTriggerRequest(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if code, err := s.trigger(ctx, qJob, user, req); err != nil {
+			writeError(w, code, err.Error())
+			return
+		}
+		status = http.StatusAccepted
+	}
+
+	runStatus, err := s.runStatus(ctx, qJob)
+	if err != nil {
+		ctxlog.Errorf(s.ctx, "Failed to get status of quarks job '%s': %s", nn, err)
+		writeError(w, http.StatusInternalServerError, "failed to get status")
+		return
+	}
+	writeJSON(w, status, runStatus)
+}
+
+// trigger queues a run of the quarks job. The errand controller starts it
+// like any other pending run.
+func (s *Server) trigger(ctx context.Context, qJob *qjv1a1.QuarksJob, user authnv1.UserInfo, req TriggerRequest) (int, error) {
+	if qJob.Status.PendingRun != "" {
+		return http.StatusConflict, fmt.Errorf("quarks job '%s' already has a pending '%s' run", qJob.GetNamespacedName(), qJob.Status.PendingRun)
+	}
+
+	qJob.Status.PendingRun = qjv1a1.TriggerReasonAPI
+	qJob.Status.Parameters = req.Parameters
+	if err := s.client.Status().Update(ctx, qJob); err != nil {
+		if apierrors.IsConflict(err) {
+			return http.StatusConflict, fmt.Errorf("quarks job '%s' was modified, try again", qJob.GetNamespacedName())
+		}
+		ctxlog.Errorf(s.ctx, "Failed to queue run of quarks job '%s': %s", qJob.GetNamespacedName(), err)
+		return http.StatusInternalServerError, fmt.Errorf("failed to queue run")
+	}
+
+	ctxlog.WithEvent(qJob, "Triggered").Infof(s.ctx, "User '%s' triggered a run of quarks job '%s'", user.Username, qJob.GetNamespacedName())
+	return http.StatusAccepted, nil
+}
+
+func (s *Server) runStatus(ctx context.Context, qJob *qjv1a1.QuarksJob) (*RunStatus, error) {
+	jobs := &batchv1.JobList{}
+	err := s.client.List(ctx, jobs,
+		crc.InNamespace(qJob.Namespace),
+		crc.MatchingLabels{qjv1a1.LabelQJobName: qJob.Name},
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing jobs of quarks job '%s' failed", qJob.GetNamespacedName())
+	}
+	sort.Slice(jobs.Items, func(i, j int) bool {
+		return jobs.Items[j].CreationTimestamp.Before(&jobs.Items[i].CreationTimestamp)
+	})
+
+	status := &RunStatus{
+		Name:       qJob.Name,
+		Namespace:  qJob.Namespace,
+		PendingRun: qJob.Status.PendingRun,
+		WaitingFor: qJob.Status.WaitingFor,
+		Parameters: qJob.Status.Parameters,
+		Conditions: qJob.Status.Conditions,
+		Jobs:       []JobStatus{},
+	}
+	for _, job := range jobs.Items {
+		status.Jobs = append(status.Jobs, JobStatus{
+			Name:           job.Name,
+			RunID:          job.Annotations[qjv1a1.AnnotationRunID],
+			TriggerReason:  job.Annotations[qjv1a1.AnnotationTriggerReason],
+			Attempt:        job.Annotations[qjv1a1.AnnotationAttempt],
+			Active:         job.Status.Active,
+			Succeeded:      job.Status.Succeeded,
+			Failed:         job.Status.Failed,
+			StartTime:      job.Status.StartTime,
+			CompletionTime: job.Status.CompletionTime,
+		})
+	}
+	return status, nil
+}
+
+// authenticate validates the request's bearer token with a TokenReview.
+// Tokens have to be issued for the server's audience, so tokens for the API
+// server or other services aren't accepted.
+func (s *Server) authenticate(ctx context.Context, r *http.Request) (authnv1.UserInfo, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return authnv1.UserInfo{}, errors.New("missing bearer token")
+	}
+
+	review := &authnv1.TokenReview{
+		Spec: authnv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{s.audience},
+		},
+	}
+	if err := s.client.Create(ctx, review); err != nil {
+		return authnv1.UserInfo{}, errors.Wrap(err, "token review failed")
+	}
+	if !review.Status.Authenticated {
+		return authnv1.UserInfo{}, errors.Errorf("invalid token: %s", review.Status.Error)
+	}
+	if !contains(review.Status.Audiences, s.audience) {
+		return authnv1.UserInfo{}, errors.Errorf("token is not valid for audience '%s'", s.audience)
+	}
+	return review.Status.User, nil
+}
+
+// authorize checks the user's permission with a SubjectAccessReview
+func (s *Server) authorize(ctx context.Context, user authnv1.UserInfo, verb, subresource string, nn types.NamespacedName) (bool, error) {
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+
+	review := &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Namespace:   nn.Namespace,
+				Verb:        verb,
+				Group:       apis.GroupName,
+				Resource:    qjv1a1.QuarksJobResourcePlural,
+				Subresource: subresource,
+				Name:        nn.Name,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+		},
+	}
+	if err := s.client.Create(ctx, review); err != nil {
+		return false, errors.Wrap(err, "subject access review failed")
+	}
+	return review.Status.Allowed, nil
+}
+
+func decodeTriggerRequest(r *http.Request, req *TriggerRequest) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil && err != io.EOF {
+		return errors.Wrap(err, "invalid request body")
+	}
+
+	for name := range req.Parameters {
+		if !parameterPattern.MatchString(name) {
+			return errors.Errorf("invalid parameter name '%s'", name)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
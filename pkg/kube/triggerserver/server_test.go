@@ -0,0 +1,236 @@
+package triggerserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest/observer"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	cfakes "code.cloudfoundry.org/quarks-job/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/quarks-job/pkg/kube/triggerserver"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		client       *cfakes.FakeClient
+		statusWriter *cfakes.FakeStatusWriter
+		logs         *observer.ObservedLogs
+		handler      http.Handler
+		qJob         *qjv1a1.QuarksJob
+		jobs         []batchv1.Job
+		reviews      []*authzv1.SubjectAccessReview
+		tokenReviews []*authnv1.TokenReview
+		allowed      bool
+		token        string
+		body         string
+	)
+
+	BeforeEach(func() {
+		qJob = &qjv1a1.QuarksJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "smoke-test", Namespace: "default"},
+			Spec:       qjv1a1.QuarksJobSpec{Trigger: qjv1a1.Trigger{Strategy: qjv1a1.TriggerManual}},
+		}
+		jobs = []batchv1.Job{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "smoke-test-old",
+					CreationTimestamp: metav1.Unix(100, 0),
+					Annotations:       map[string]string{qjv1a1.AnnotationTriggerReason: "manual"},
+				},
+				Status: batchv1.JobStatus{Succeeded: 1},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "smoke-test-new",
+					CreationTimestamp: metav1.Unix(200, 0),
+					Annotations:       map[string]string{qjv1a1.AnnotationTriggerReason: "api", qjv1a1.AnnotationRunID: "run-1"},
+				},
+				Status: batchv1.JobStatus{Active: 1},
+			},
+		}
+		reviews = nil
+		tokenReviews = nil
+		allowed = true
+		token = "ci-token"
+		body = ""
+
+		client = &cfakes.FakeClient{}
+		client.CreateCalls(func(_ context.Context, object crc.Object, _ ...crc.CreateOption) error {
+			switch object := object.(type) {
+			case *authnv1.TokenReview:
+				tokenReviews = append(tokenReviews, object)
+				switch object.Spec.Token {
+				case "ci-token":
+					object.Status.Audiences = object.Spec.Audiences
+				case "api-server-token":
+					// Authenticators which aren't audience aware
+					// ignore the requested audiences
+				default:
+					return nil
+				}
+				object.Status.Authenticated = true
+				object.Status.User = authnv1.UserInfo{Username: "system:serviceaccount:ci:runner", Groups: []string{"system:serviceaccounts"}}
+			case *authzv1.SubjectAccessReview:
+				reviews = append(reviews, object)
+				object.Status.Allowed = allowed
+			}
+			return nil
+		})
+		client.GetCalls(func(_ context.Context, nn types.NamespacedName, object crc.Object) error {
+			if nn.Name != qJob.Name {
+				return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+			}
+			qJob.DeepCopyInto(object.(*qjv1a1.QuarksJob))
+			return nil
+		})
+		client.ListCalls(func(_ context.Context, object crc.ObjectList, _ ...crc.ListOption) error {
+			list := batchv1.JobList{Items: jobs}
+			list.DeepCopyInto(object.(*batchv1.JobList))
+			return nil
+		})
+		statusWriter = &cfakes.FakeStatusWriter{}
+		client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+
+		var log = helper.NewTestLogger
+		l, sugared := log()
+		logs = l
+		ctx := ctxlog.NewContextWithRecorder(ctxlog.NewParentContext(sugared), "trigger-server", record.NewFakeRecorder(10))
+		handler = triggerserver.New(ctx, client, ":0", "", "", "quarks-job-trigger").Handler()
+	})
+
+	request := func(method, path string) (*httptest.ResponseRecorder, triggerserver.RunStatus) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		status := triggerserver.RunStatus{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &status)
+		return rec, status
+	}
+
+	Describe("triggering a run", func() {
+		trigger := func() (*httptest.ResponseRecorder, triggerserver.RunStatus) {
+			return request(http.MethodPost, "/v1/namespaces/default/quarksjobs/smoke-test/runs")
+		}
+
+		It("queues a run with the parameters", func() {
+			body = `{"parameters": {"VERSION": "1.2.3"}}`
+
+			rec, status := trigger()
+			Expect(rec.Code).To(Equal(http.StatusAccepted))
+			Expect(status.PendingRun).To(Equal(qjv1a1.TriggerReasonAPI))
+
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			updated := object.(*qjv1a1.QuarksJob)
+			Expect(updated.Status.PendingRun).To(Equal(qjv1a1.TriggerReasonAPI))
+			Expect(updated.Status.Parameters).To(Equal(map[string]string{"VERSION": "1.2.3"}))
+			Expect(logs.FilterMessageSnippet("User 'system:serviceaccount:ci:runner' triggered a run of quarks job 'default/smoke-test'").Len()).To(Equal(1))
+		})
+
+		It("checks the permission for the trigger subresource", func() {
+			trigger()
+			Expect(reviews).To(HaveLen(1))
+			attributes := reviews[0].Spec.ResourceAttributes
+			Expect(attributes.Verb).To(Equal("create"))
+			Expect(attributes.Resource).To(Equal("quarksjobs"))
+			Expect(attributes.Subresource).To(Equal("trigger"))
+			Expect(attributes.Namespace).To(Equal("default"))
+			Expect(attributes.Name).To(Equal("smoke-test"))
+			Expect(reviews[0].Spec.User).To(Equal("system:serviceaccount:ci:runner"))
+		})
+
+		It("rejects requests without valid token", func() {
+			token = ""
+			rec, _ := trigger()
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+
+			token = "other-token"
+			rec, _ = trigger()
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("requires tokens for the server's audience", func() {
+			rec, _ := trigger()
+			Expect(rec.Code).To(Equal(http.StatusAccepted))
+			Expect(tokenReviews[0].Spec.Audiences).To(Equal([]string{"quarks-job-trigger"}))
+
+			token = "api-server-token"
+			rec, _ = trigger()
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+			Expect(logs.FilterMessageSnippet("token is not valid for audience 'quarks-job-trigger'").Len()).To(Equal(1))
+		})
+
+		It("rejects users without permission", func() {
+			allowed = false
+			rec, _ := trigger()
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("rejects invalid parameter names", func() {
+			body = `{"parameters": {"NOT-VALID": "x"}}`
+			rec, _ := trigger()
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("does not queue a second run", func() {
+			qJob.Status.PendingRun = qjv1a1.TriggerReasonAPI
+			rec, _ := trigger()
+			Expect(rec.Code).To(Equal(http.StatusConflict))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("returns not found for unknown quarks jobs", func() {
+			rec, _ := request(http.MethodPost, "/v1/namespaces/default/quarksjobs/unknown/runs")
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("polling the status", func() {
+		It("returns the status and the jobs, newest first", func() {
+			qJob.Status.Conditions = []metav1.Condition{{Type: qjv1a1.ConditionSucceeded, Status: metav1.ConditionUnknown, Reason: "Running"}}
+
+			rec, status := request(http.MethodGet, "/v1/namespaces/default/quarksjobs/smoke-test")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(status.Conditions).To(HaveLen(1))
+			Expect(status.Jobs).To(HaveLen(2))
+			Expect(status.Jobs[0].Name).To(Equal("smoke-test-new"))
+			Expect(status.Jobs[0].RunID).To(Equal("run-1"))
+			Expect(status.Jobs[0].Active).To(Equal(int32(1)))
+			Expect(status.Jobs[1].Succeeded).To(Equal(int32(1)))
+
+			Expect(reviews[0].Spec.ResourceAttributes.Verb).To(Equal("get"))
+			Expect(reviews[0].Spec.ResourceAttributes.Subresource).To(BeEmpty())
+		})
+
+		It("doesn't allow other methods", func() {
+			rec, _ := request(http.MethodDelete, "/v1/namespaces/default/quarksjobs/smoke-test")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
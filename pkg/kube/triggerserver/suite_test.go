@@ -0,0 +1,13 @@
+package triggerserver_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTriggerServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TriggerServer Suite")
+}
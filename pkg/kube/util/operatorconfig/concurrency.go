@@ -0,0 +1,32 @@
+package operatorconfig
+
+import (
+	"fmt"
+)
+
+// Concurrency limits the jobs created by quarks jobs, which run at the same
+// time. Zero disables a limit.
+type Concurrency struct {
+	// MaxRunningJobs is the maximum number of running jobs in the cluster
+	MaxRunningJobs int
+	// MaxRunningJobsPerNamespace is the default maximum number of running
+	// jobs in a namespace
+	MaxRunningJobsPerNamespace int
+}
+
+// Enabled returns true if any limit is set
+func (c Concurrency) Enabled() bool {
+	return c.MaxRunningJobs > 0 || c.MaxRunningJobsPerNamespace > 0
+}
+
+// SetupConcurrencyLimits sets the maximum number of jobs created by quarks
+// jobs, which run at the same time in the cluster and in each namespace.
+// Zero disables the limit.
+func (c *Config) SetupConcurrencyLimits(maxRunningJobs, maxRunningJobsPerNamespace int) error {
+	if maxRunningJobs < 0 || maxRunningJobsPerNamespace < 0 {
+		return fmt.Errorf("concurrency limits must not be negative")
+	}
+	c.Concurrency.MaxRunningJobs = maxRunningJobs
+	c.Concurrency.MaxRunningJobsPerNamespace = maxRunningJobsPerNamespace
+	return nil
+}
@@ -0,0 +1,31 @@
+// Package operatorconfig holds the quarks-job specific operator settings,
+// which are not part of the common quarks-utils config
+package operatorconfig
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Config controls the quarks-job specific behaviour of the controllers
+type Config struct {
+	ServiceAccountToken ServiceAccountToken
+	Sidecar             Sidecar
+	TriggerServer       TriggerServer
+	Concurrency         Concurrency
+}
+
+// NewDefaultConfig returns a new Config with the default settings
+func NewDefaultConfig() *Config {
+	return &Config{
+		ServiceAccountToken: ServiceAccountToken{
+			Expiration: DefaultServiceAccountTokenExpiration,
+		},
+		Sidecar: Sidecar{
+			SecurityContext:       RestrictedSecurityContext(),
+			OutputVolumeSizeLimit: resource.MustParse(DefaultOutputVolumeSizeLimit),
+		},
+		TriggerServer: TriggerServer{
+			Audience: DefaultTriggerServerAudience,
+		},
+	}
+}
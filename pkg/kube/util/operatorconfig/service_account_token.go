@@ -0,0 +1,38 @@
+package operatorconfig
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultServiceAccountTokenExpiration is the default lifetime in
+	// seconds of projected service account tokens
+	DefaultServiceAccountTokenExpiration = 3600
+	// minServiceAccountTokenExpiration is the minimum accepted by the kubelet
+	minServiceAccountTokenExpiration = 600
+)
+
+// ServiceAccountToken configures the service account token used by the
+// persist-output container
+type ServiceAccountToken struct {
+	// Projected enables projected service account tokens, instead of
+	// mounting the legacy token secret
+	Projected bool
+	// Audience of the projected token, empty means the API server's
+	// default audience
+	Audience string
+	// Expiration is the requested lifetime of the projected token in
+	// seconds
+	Expiration int64
+}
+
+// SetupServiceAccountToken sets the audience and expiration of the
+// projected service account token, used by the persist-output container
+func (c *Config) SetupServiceAccountToken(audience string, expiration int64) error {
+	if expiration < minServiceAccountTokenExpiration {
+		return fmt.Errorf("service account token expiration must be at least %d seconds", minServiceAccountTokenExpiration)
+	}
+	c.ServiceAccountToken.Audience = audience
+	c.ServiceAccountToken.Expiration = expiration
+	return nil
+}
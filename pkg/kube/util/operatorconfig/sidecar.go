@@ -0,0 +1,99 @@
+package operatorconfig
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+)
+
+const (
+	// DefaultOutputVolumeSizeLimit limits the emptyDir volumes the
+	// containers write their output to
+	DefaultOutputVolumeSizeLimit = "16Mi"
+
+	// sidecarUser is the uid of the quarks user in the operator image
+	sidecarUser = 1000
+)
+
+// Sidecar holds the defaults of the output-persist container, which
+// QuarksJobs can override
+type Sidecar struct {
+	Resources       corev1.ResourceRequirements
+	SecurityContext *corev1.SecurityContext
+	// OutputVolumeSizeLimit limits the emptyDir volumes the containers
+	// write their output to, zero if unlimited
+	OutputVolumeSizeLimit resource.Quantity
+}
+
+// RestrictedSecurityContext returns the default security context of the
+// output-persist container, which satisfies the restricted Pod Security
+// Standard
+func RestrictedSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             pointers.Bool(true),
+		RunAsUser:                pointers.Int64(sidecarUser),
+		RunAsGroup:               pointers.Int64(sidecarUser),
+		AllowPrivilegeEscalation: pointers.Bool(false),
+		ReadOnlyRootFilesystem:   pointers.Bool(true),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// SetupSidecar sets the default resources and security context of the
+// output-persist container. Both are JSON encoded, an empty security context
+// falls back to RestrictedSecurityContext.
+func (c *Config) SetupSidecar(resources string, securityContext string) error {
+	var r corev1.ResourceRequirements
+	if resources != "" {
+		if err := json.Unmarshal([]byte(resources), &r); err != nil {
+			return errors.Wrap(err, "failed to parse sidecar resources")
+		}
+	}
+
+	sc := RestrictedSecurityContext()
+	if securityContext != "" {
+		sc = &corev1.SecurityContext{}
+		if err := json.Unmarshal([]byte(securityContext), sc); err != nil {
+			return errors.Wrap(err, "failed to parse sidecar security context")
+		}
+	}
+
+	c.Sidecar.Resources = r
+	c.Sidecar.SecurityContext = sc
+	return nil
+}
+
+// SetupOutputVolumeSizeLimit sets the size limit of the output volumes,
+// an empty string removes the limit
+func (c *Config) SetupOutputVolumeSizeLimit(limit string) error {
+	if limit == "" {
+		c.Sidecar.OutputVolumeSizeLimit = resource.Quantity{}
+		return nil
+	}
+
+	q, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse output volume size limit '%s'", limit)
+	}
+	c.Sidecar.OutputVolumeSizeLimit = q
+	return nil
+}
+
+// VolumeSizeLimit returns a copy of the size limit of the output volumes,
+// nil if unlimited
+func (s Sidecar) VolumeSizeLimit() *resource.Quantity {
+	if s.OutputVolumeSizeLimit.IsZero() {
+		return nil
+	}
+	q := s.OutputVolumeSizeLimit.DeepCopy()
+	return &q
+}
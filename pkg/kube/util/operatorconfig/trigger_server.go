@@ -0,0 +1,44 @@
+package operatorconfig
+
+import (
+	"fmt"
+)
+
+// DefaultTriggerServerAudience is the audience, which tokens of requests to
+// the HTTP trigger endpoint need
+const DefaultTriggerServerAudience = "quarks-job-trigger"
+
+// TriggerServer configures the HTTP trigger endpoint
+type TriggerServer struct {
+	// Address the endpoint listens on, empty if it's disabled
+	Address string
+	// CertFile and KeyFile are the TLS certificate of the endpoint, empty
+	// if it doesn't use TLS
+	CertFile string
+	KeyFile  string
+	// Audience, which tokens of requests need
+	Audience string
+}
+
+// SetupTriggerServer sets the listen address of the HTTP trigger endpoint,
+// the files of its TLS certificate and the audience of the tokens it
+// accepts. An empty address disables the endpoint. Without certificate it
+// only serves plain HTTP if insecure is set, as clients send their tokens.
+func (c *Config) SetupTriggerServer(address, certFile, keyFile, audience string, insecure bool) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("trigger server needs both a certificate and a key file")
+	}
+	if address != "" && certFile == "" && !insecure {
+		return fmt.Errorf("trigger server needs a certificate and a key file, unless it's allowed to serve plain HTTP")
+	}
+	if audience == "" {
+		return fmt.Errorf("trigger server needs a token audience")
+	}
+	c.TriggerServer = TriggerServer{
+		Address:  address,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Audience: audience,
+	}
+	return nil
+}
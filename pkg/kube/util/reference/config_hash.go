@@ -0,0 +1,169 @@
+package reference
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
+)
+
+// WatchedConfigNames returns the names of the config maps and secrets
+// referenced by the quarks job's template, which are selected by its
+// WatchReferences
+func WatchedConfigNames(qJob *qjv1a1.QuarksJob) (map[string]struct{}, map[string]struct{}) {
+	configMaps, secrets := vss.GetConfigNamesFromSpec(qJob.Spec.Template.Spec.Template.Spec)
+	for name := range configMaps {
+		if !qJob.Spec.WatchReferences.Watches(qjv1a1.ReferenceConfigMap, name) {
+			delete(configMaps, name)
+		}
+	}
+	for name := range secrets {
+		if !qJob.Spec.WatchReferences.Watches(qjv1a1.ReferenceSecret, name) {
+			delete(secrets, name)
+		}
+	}
+	return configMaps, secrets
+}
+
+// ConfigHash returns a hash of the data of the watched config maps and
+// secrets and of the fields selected by the trigger.watch entries of the
+// quarks job. It doesn't depend on the order of keys or on metadata.
+// Versioned secrets are resolved to their latest version, like the job
+// creator does.
+func ConfigHash(ctx context.Context, client crc.Client, store vss.VersionedSecretStore, qJob *qjv1a1.QuarksJob) (string, error) {
+	h := sha256.New()
+	configMaps, secrets := WatchedConfigNames(qJob)
+
+	for _, name := range sortedNames(configMaps) {
+		cm := &corev1.ConfigMap{}
+		err := client.Get(ctx, types.NamespacedName{Name: name, Namespace: qJob.Namespace}, cm)
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(h, "%s missing\n", qjv1a1.Reference(qjv1a1.ReferenceConfigMap, name))
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get config map '%s/%s'", qJob.Namespace, name)
+		}
+
+		data := make(map[string][]byte, len(cm.Data))
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		fmt.Fprintf(h, "%s\n", qjv1a1.Reference(qjv1a1.ReferenceConfigMap, name))
+		writeData(h, data)
+		writeData(h, cm.BinaryData)
+	}
+
+	for _, name := range sortedNames(secrets) {
+		s, err := latestSecret(ctx, client, store, qJob.Namespace, name)
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(h, "%s missing\n", qjv1a1.Reference(qjv1a1.ReferenceSecret, name))
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s\n", qjv1a1.Reference(qjv1a1.ReferenceSecret, s.Name))
+		writeData(h, s.Data)
+	}
+
+	for _, w := range qJob.Spec.Trigger.Watch {
+		if err := writeWatched(ctx, client, h, qJob.Namespace, w); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// latestSecret returns the latest version of a versioned secret, or the
+// secret itself if it isn't versioned
+func latestSecret(ctx context.Context, client crc.Client, store vss.VersionedSecretStore, namespace string, name string) (*corev1.Secret, error) {
+	if prefix := vss.NamePrefix(name); prefix != "" {
+		latest, err := store.Latest(ctx, namespace, prefix)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get latest versioned secret '%s/%s'", namespace, prefix)
+		}
+		if err == nil && vss.IsVersionedSecret(*latest) {
+			return latest, nil
+		}
+	}
+
+	s := &corev1.Secret{}
+	err := client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, s)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get secret '%s/%s'", namespace, name)
+	}
+	return s, err
+}
+
+// writeWatched adds the watched fields of all objects selected by the watch
+// trigger to the hash
+func writeWatched(ctx context.Context, client crc.Client, h hash.Hash, namespace string, w qjv1a1.WatchTrigger) error {
+	gvk := w.GroupVersionKind()
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+	opts := []crc.ListOption{crc.InNamespace(namespace)}
+	if w.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+		if err != nil {
+			return errors.Wrapf(err, "invalid selector for watched %s", gvk.Kind)
+		}
+		opts = append(opts, crc.MatchingLabelsSelector{Selector: selector})
+	}
+	if err := client.List(ctx, list, opts...); err != nil {
+		return errors.Wrapf(err, "failed to list watched %s", gvk.Kind)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].GetName() < list.Items[j].GetName() })
+	for i := range list.Items {
+		object := &list.Items[i]
+		if !Watches(w, object) {
+			continue
+		}
+		fields, err := watchedFields(w, object)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get watched fields of %s '%s/%s'", gvk.Kind, namespace, object.GetName())
+		}
+		fmt.Fprintf(h, "%s/%s\n%d:%s\n", gvk.Kind, object.GetName(), len(fields), fields)
+	}
+	return nil
+}
+
+// writeData adds the data to the hash, sorted by key. Lengths are written
+// to tell keys and values apart.
+func writeData(h hash.Hash, data map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%s=%d:", len(k), k, len(data[k]))
+		_, _ = h.Write(data[k])
+	}
+}
+
+func sortedNames(names map[string]struct{}) []string {
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,104 @@
+package reference
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+)
+
+// PodReference is an object referenced by a pod spec
+type PodReference struct {
+	Kind qjv1a1.ReferenceKind
+	Name string
+	// Key is the referenced key of a config map or secret, empty if the
+	// whole object is referenced
+	Key string
+	// Optional references don't prevent the pod from starting
+	Optional bool
+}
+
+// String formats the reference for QuarksJobStatus.MissingReferences
+func (r PodReference) String() string {
+	if r.Key != "" {
+		return qjv1a1.Reference(r.Kind, r.Name) + ":" + r.Key
+	}
+	return qjv1a1.Reference(r.Kind, r.Name)
+}
+
+// GetPodReferences returns all config maps, secrets, persistent volume
+// claims and service accounts referenced by the pod spec
+func GetPodReferences(spec corev1.PodSpec) []PodReference {
+	refs := []PodReference{}
+	add := func(kind qjv1a1.ReferenceKind, name string, optional *bool, keys ...string) {
+		isOptional := optional != nil && *optional
+		refs = append(refs, PodReference{Kind: kind, Name: name, Optional: isOptional})
+		for _, key := range keys {
+			refs = append(refs, PodReference{Kind: kind, Name: name, Key: key, Optional: isOptional})
+		}
+	}
+
+	for _, volume := range spec.Volumes {
+		if s := volume.Secret; s != nil {
+			add(qjv1a1.ReferenceSecret, s.SecretName, s.Optional, itemKeys(s.Items)...)
+		}
+		if c := volume.ConfigMap; c != nil {
+			add(qjv1a1.ReferenceConfigMap, c.Name, c.Optional, itemKeys(c.Items)...)
+		}
+		if p := volume.PersistentVolumeClaim; p != nil {
+			add(qjv1a1.ReferencePersistentVolumeClaim, p.ClaimName, nil)
+		}
+		if p := volume.Projected; p != nil {
+			for _, source := range p.Sources {
+				if s := source.Secret; s != nil {
+					add(qjv1a1.ReferenceSecret, s.Name, s.Optional, itemKeys(s.Items)...)
+				}
+				if c := source.ConfigMap; c != nil {
+					add(qjv1a1.ReferenceConfigMap, c.Name, c.Optional, itemKeys(c.Items)...)
+				}
+			}
+		}
+	}
+
+	containers := append([]corev1.Container{}, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if s := envFrom.SecretRef; s != nil {
+				add(qjv1a1.ReferenceSecret, s.Name, s.Optional)
+			}
+			if c := envFrom.ConfigMapRef; c != nil {
+				add(qjv1a1.ReferenceConfigMap, c.Name, c.Optional)
+			}
+		}
+
+		for _, envVar := range container.Env {
+			if envVar.ValueFrom == nil {
+				continue
+			}
+			if s := envVar.ValueFrom.SecretKeyRef; s != nil {
+				add(qjv1a1.ReferenceSecret, s.Name, s.Optional, s.Key)
+			}
+			if c := envVar.ValueFrom.ConfigMapKeyRef; c != nil {
+				add(qjv1a1.ReferenceConfigMap, c.Name, c.Optional, c.Key)
+			}
+		}
+	}
+
+	for _, secret := range spec.ImagePullSecrets {
+		add(qjv1a1.ReferenceSecret, secret.Name, nil)
+	}
+
+	if spec.ServiceAccountName != "" {
+		add(qjv1a1.ReferenceServiceAccount, spec.ServiceAccountName, nil)
+	}
+
+	return refs
+}
+
+func itemKeys(items []corev1.KeyToPath) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
@@ -16,13 +16,15 @@ import (
 	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
 )
 
-// GetReconciles returns reconciliation requests for the QuarksJobs
-// that reference an object. The object can be a ConfigMap or a Secret
+// GetReconciles returns reconciliation requests for the QuarksJobs with
+// UpdateOnConfigChange, which reference an object and watch it according to
+// their WatchReferences. The object can be a ConfigMap or a Secret
 func GetReconciles(ctx context.Context, client crc.Client, object apis.Object) ([]reconcile.Request, error) {
 	objReferencedBy := func(parent qjv1a1.QuarksJob) (bool, error) {
 		var (
 			objectReferences map[string]bool
 			err              error
+			kind             qjv1a1.ReferenceKind
 			name             string
 			versionedSecret  bool
 		)
@@ -30,9 +32,11 @@ func GetReconciles(ctx context.Context, client crc.Client, object apis.Object) (
 		switch object := object.(type) {
 		case *corev1.ConfigMap:
 			objectReferences = podref.GetConfMapRefFromPod(parent.Spec.Template.Spec.Template.Spec)
+			kind = qjv1a1.ReferenceConfigMap
 			name = object.Name
 		case *corev1.Secret:
 			objectReferences = podref.GetSecretRefFromPodSpec(parent.Spec.Template.Spec.Template.Spec)
+			kind = qjv1a1.ReferenceSecret
 			name = object.Name
 			versionedSecret = vss.IsVersionedSecret(*object)
 		default:
@@ -43,6 +47,10 @@ func GetReconciles(ctx context.Context, client crc.Client, object apis.Object) (
 			return false, errors.Wrap(err, "error listing references")
 		}
 
+		if !parent.Spec.WatchReferences.Watches(kind, name) {
+			return false, nil
+		}
+
 		if versionedSecret {
 			keys := make([]string, len(objectReferences))
 			i := 0
@@ -69,7 +77,7 @@ func GetReconciles(ctx context.Context, client crc.Client, object apis.Object) (
 	}
 
 	for _, qJob := range quarksJobs.Items {
-		if !(qJob.Spec.UpdateOnConfigChange && qJob.IsAutoErrand()) {
+		if !qJob.Spec.UpdateOnConfigChange {
 			continue
 		}
 		isRef, err := objReferencedBy(qJob)
@@ -89,6 +97,48 @@ func GetReconciles(ctx context.Context, client crc.Client, object apis.Object) (
 	return result, nil
 }
 
+// GetMissingReferenceReconciles returns reconciliation requests for the
+// QuarksJobs, which are waiting for the object or one of its keys to
+// appear. The object can be a ConfigMap, Secret, PersistentVolumeClaim or
+// ServiceAccount
+func GetMissingReferenceReconciles(ctx context.Context, client crc.Client, object apis.Object) ([]reconcile.Request, error) {
+	var kind qjv1a1.ReferenceKind
+	switch object.(type) {
+	case *corev1.ConfigMap:
+		kind = qjv1a1.ReferenceConfigMap
+	case *corev1.Secret:
+		kind = qjv1a1.ReferenceSecret
+	case *corev1.PersistentVolumeClaim:
+		kind = qjv1a1.ReferencePersistentVolumeClaim
+	case *corev1.ServiceAccount:
+		kind = qjv1a1.ReferenceServiceAccount
+	default:
+		return nil, errors.New("can't get reconciles for unknown object type; supported types are ConfigMap, Secret, PersistentVolumeClaim and ServiceAccount")
+	}
+
+	namespace := object.GetNamespace()
+	result := []reconcile.Request{}
+
+	quarksJobs := &qjv1a1.QuarksJobList{}
+	err := client.List(ctx, quarksJobs, crc.InNamespace(namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list QuarksJobs for reconciles")
+	}
+
+	for _, qJob := range quarksJobs.Items {
+		if qJob.Status.IsMissingReference(kind, object.GetName()) {
+			log.Debugf(ctx, "QuarksJob '%s' is waiting for '%s'", qJob.GetNamespacedName(), qjv1a1.Reference(kind, object.GetName()))
+			result = append(result, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      qJob.Name,
+					Namespace: qJob.Namespace,
+				}})
+		}
+	}
+
+	return result, nil
+}
+
 // SkipReconciles returns true if the object is stale, and shouldn't be enqueued for reconciliation
 // The object can be a ConfigMap or a Secret
 func SkipReconciles(ctx context.Context, client crc.Client, object apis.Object) bool {
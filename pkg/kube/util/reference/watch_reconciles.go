@@ -0,0 +1,130 @@
+package reference
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// GetWatchReconciles returns reconciliation requests for the auto-errands,
+// which watch the updated object and whose watched fields changed
+func GetWatchReconciles(ctx context.Context, client crc.Client, old, new *unstructured.Unstructured) ([]reconcile.Request, error) {
+	namespace := new.GetNamespace()
+	result := []reconcile.Request{}
+
+	quarksJobs := &qjv1a1.QuarksJobList{}
+	err := client.List(ctx, quarksJobs, crc.InNamespace(namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list QuarksJobs for reconciles")
+	}
+
+	for _, qJob := range quarksJobs.Items {
+		if !qJob.IsAutoErrand() {
+			continue
+		}
+
+		for _, w := range qJob.Spec.Trigger.Watch {
+			if !Watches(w, new) {
+				continue
+			}
+
+			changed, err := watchedFieldsChanged(w, old, new)
+			if err != nil {
+				log.Errorf(ctx, "Failed to compare '%s' of '%s/%s' for QuarksJob '%s': %s", w.JSONPath, namespace, new.GetName(), qJob.GetNamespacedName(), err)
+				continue
+			}
+			if changed {
+				log.Debugf(ctx, "QuarksJob '%s' watches '%s/%s'", qJob.GetNamespacedName(), namespace, new.GetName())
+				result = append(result, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      qJob.Name,
+						Namespace: qJob.Namespace,
+					}})
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Watches returns true if the watch trigger selects the object
+func Watches(w qjv1a1.WatchTrigger, object *unstructured.Unstructured) bool {
+	if object.GroupVersionKind() != w.GroupVersionKind() {
+		return false
+	}
+	if w.Name != "" && w.Name != object.GetName() {
+		return false
+	}
+	if w.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+		if err != nil || !selector.Matches(labels.Set(object.GetLabels())) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchedFieldsChanged compares the fields selected by the JSONPath, or all
+// fields except metadata and status
+func watchedFieldsChanged(w qjv1a1.WatchTrigger, old, new *unstructured.Unstructured) (bool, error) {
+	oldFields, err := watchedFields(w, old)
+	if err != nil {
+		return false, err
+	}
+	newFields, err := watchedFields(w, new)
+	if err != nil {
+		return false, err
+	}
+	return oldFields != newFields, nil
+}
+
+// watchedFields returns the fields selected by the JSONPath, or all fields
+// except metadata and status as JSON
+func watchedFields(w qjv1a1.WatchTrigger, object *unstructured.Unstructured) (string, error) {
+	if w.JSONPath == "" {
+		fields, err := json.Marshal(withoutMetadata(object.Object))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal watched fields")
+		}
+		return string(fields), nil
+	}
+
+	path := w.JSONPath
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	j := jsonpath.New("watch").AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return "", errors.Wrap(err, "invalid JSONPath")
+	}
+
+	fields := bytes.Buffer{}
+	if err := j.Execute(&fields, object.Object); err != nil {
+		return "", errors.Wrap(err, "failed to evaluate JSONPath")
+	}
+	return fields.String(), nil
+}
+
+func withoutMetadata(object map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(object))
+	for k, v := range object {
+		if k != "metadata" && k != "status" {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -79,9 +79,6 @@ func (c *Catalog) ConfigJobTemplate() batchv1b1.JobTemplateSpec {
 	return batchv1b1.JobTemplateSpec{
 		Spec: batchv1.JobSpec{
 			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{"delete": "pod"},
-				},
 				Spec: corev1.PodSpec{
 					RestartPolicy:                 corev1.RestartPolicyNever,
 					TerminationGracePeriodSeconds: &one,
@@ -201,6 +198,25 @@ func (c *Catalog) CmdJobTemplate(cmd []string) batchv1b1.JobTemplateSpec {
 	}
 }
 
+// RestrictedErrandQuarksJob is an errand, whose pod template satisfies the
+// restricted Pod Security Standard
+func (c *Catalog) RestrictedErrandQuarksJob(name, namespace string) qjv1a1.QuarksJob {
+	qJob := c.ErrandQuarksJob(name, namespace)
+	qJob.Spec.Template.Spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot: pointers.Bool(true),
+		RunAsUser:    pointers.Int64(65534),
+	}
+	for i := range qJob.Spec.Template.Spec.Template.Spec.Containers {
+		qJob.Spec.Template.Spec.Template.Spec.Containers[i].SecurityContext = &corev1.SecurityContext{
+			AllowPrivilegeEscalation: pointers.Bool(false),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+		}
+	}
+	return qJob
+}
+
 // DefaultQuarksJob default values
 func (c *Catalog) DefaultQuarksJob(name, namespace string) *qjv1a1.QuarksJob {
 	cmd := []string{"sleep", "1"}
@@ -335,3 +351,38 @@ func (c *Catalog) OutputQuarksJob(name string) qjv1a1.QuarksJob {
 		},
 	}
 }
+
+// DefaultQuarksWorkflow has a step, which fans out to two parallel steps,
+// and a final step depending on both
+func (c *Catalog) DefaultQuarksWorkflow(name, namespace string) *qjv1a1.QuarksWorkflow {
+	cmd := []string{"sleep", "1"}
+	return &qjv1a1.QuarksWorkflow{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: "workflow-uid"},
+		Spec: qjv1a1.QuarksWorkflowSpec{
+			Steps: []qjv1a1.WorkflowStep{
+				{
+					Name:      "final",
+					DependsOn: []string{"left", "right"},
+					Template:  qjv1a1.QuarksJobSpec{Template: c.CmdJobTemplate(cmd)},
+				},
+				{
+					Name: "generate",
+					Template: qjv1a1.QuarksJobSpec{
+						Template: c.CmdJobTemplate(cmd),
+						Output:   &qjv1a1.Output{OutputMap: c.DefaultOutputMap()},
+					},
+				},
+				{
+					Name:      "left",
+					DependsOn: []string{"generate"},
+					Template:  qjv1a1.QuarksJobSpec{Template: c.CmdJobTemplate(cmd)},
+				},
+				{
+					Name:      "right",
+					DependsOn: []string{"generate"},
+					Template:  qjv1a1.QuarksJobSpec{Template: c.CmdJobTemplate(cmd)},
+				},
+			},
+		},
+	}
+}
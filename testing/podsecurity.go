@@ -0,0 +1,174 @@
+package testing
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restrictedVolumeTypes are the volume sources allowed by the restricted
+// Pod Security Standard
+var restrictedVolumeTypes = map[string]func(corev1.VolumeSource) bool{
+	"configMap":             func(v corev1.VolumeSource) bool { return v.ConfigMap != nil },
+	"csi":                   func(v corev1.VolumeSource) bool { return v.CSI != nil },
+	"downwardAPI":           func(v corev1.VolumeSource) bool { return v.DownwardAPI != nil },
+	"emptyDir":              func(v corev1.VolumeSource) bool { return v.EmptyDir != nil },
+	"ephemeral":             func(v corev1.VolumeSource) bool { return v.Ephemeral != nil },
+	"persistentVolumeClaim": func(v corev1.VolumeSource) bool { return v.PersistentVolumeClaim != nil },
+	"projected":             func(v corev1.VolumeSource) bool { return v.Projected != nil },
+	"secret":                func(v corev1.VolumeSource) bool { return v.Secret != nil },
+}
+
+// baselineSELinuxTypes are the SELinux types allowed by the baseline Pod
+// Security Standard
+var baselineSELinuxTypes = map[string]bool{
+	"":                 true,
+	"container_t":      true,
+	"container_init_t": true,
+	"container_kvm_t":  true,
+}
+
+// baselineSysctls are the safe sysctls allowed by the baseline Pod Security
+// Standard
+var baselineSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+}
+
+// RestrictedPodSecurityViolations evaluates a pod spec against the
+// restricted Pod Security Standard, which includes the baseline one. It
+// returns a description for each violation, the list is empty if the pod
+// spec is compliant.
+func RestrictedPodSecurityViolations(spec corev1.PodSpec) []string {
+	violations := []string{}
+	add := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		add("pod: host namespaces must not be shared")
+	}
+
+	for _, volume := range spec.Volumes {
+		allowed := false
+		for _, isType := range restrictedVolumeTypes {
+			if isType(volume.VolumeSource) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			add("volume %q: restricted volume type", volume.Name)
+		}
+	}
+
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+	if podSC.RunAsNonRoot != nil && !*podSC.RunAsNonRoot {
+		add("pod: runAsNonRoot must not be false")
+	}
+	if podSC.RunAsUser != nil && *podSC.RunAsUser == 0 {
+		add("pod: runAsUser must not be 0")
+	}
+	if podSC.SeccompProfile != nil && !allowedSeccompProfile(podSC.SeccompProfile) {
+		add("pod: seccompProfile must be RuntimeDefault or Localhost")
+	}
+	if podSC.SELinuxOptions != nil {
+		violations = append(violations, seLinuxViolations("pod", podSC.SELinuxOptions)...)
+	}
+	for _, sysctl := range podSC.Sysctls {
+		if !baselineSysctls[sysctl.Name] {
+			add("pod: forbidden sysctl %q", sysctl.Name)
+		}
+	}
+
+	containers := append([]corev1.Container{}, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, container := range containers {
+		name := fmt.Sprintf("container %q", container.Name)
+
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				add("%s: hostPort must not be set", name)
+			}
+		}
+
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if sc.Privileged != nil && *sc.Privileged {
+			add("%s: must not be privileged", name)
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			add("%s: allowPrivilegeEscalation must be false", name)
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			add("%s: procMount must be Default", name)
+		}
+
+		runAsNonRoot := podSC.RunAsNonRoot != nil && *podSC.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = *sc.RunAsNonRoot
+		}
+		if !runAsNonRoot {
+			add("%s: runAsNonRoot must be true", name)
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			add("%s: runAsUser must not be 0", name)
+		}
+
+		seccomp := podSC.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+		if seccomp == nil || !allowedSeccompProfile(seccomp) {
+			add("%s: seccompProfile must be RuntimeDefault or Localhost", name)
+		}
+
+		if sc.SELinuxOptions != nil {
+			violations = append(violations, seLinuxViolations(name, sc.SELinuxOptions)...)
+		}
+
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, c := range sc.Capabilities.Drop {
+				if c == "ALL" {
+					dropsAll = true
+				}
+			}
+			for _, c := range sc.Capabilities.Add {
+				if c != "NET_BIND_SERVICE" {
+					add("%s: must not add capability %q", name, c)
+				}
+			}
+		}
+		if !dropsAll {
+			add("%s: must drop ALL capabilities", name)
+		}
+	}
+
+	return violations
+}
+
+func allowedSeccompProfile(profile *corev1.SeccompProfile) bool {
+	return profile.Type == corev1.SeccompProfileTypeRuntimeDefault ||
+		profile.Type == corev1.SeccompProfileTypeLocalhost
+}
+
+func seLinuxViolations(name string, options *corev1.SELinuxOptions) []string {
+	violations := []string{}
+	if !baselineSELinuxTypes[options.Type] {
+		violations = append(violations, fmt.Sprintf("%s: forbidden SELinux type %q", name, options.Type))
+	}
+	if options.User != "" || options.Role != "" {
+		violations = append(violations, fmt.Sprintf("%s: SELinux user and role must not be set", name))
+	}
+	return violations
+}